@@ -5,6 +5,7 @@ go 1.14
 require (
 	github.com/spf13/cobra v0.0.5
 	github.com/stretchr/testify v1.2.2
-	golang.org/x/mod v0.4.1
-	golang.org/x/tools v0.0.0-20191130070609-6e064ea0cf2d
+	golang.org/x/mod v0.12.0
+	golang.org/x/tools v0.1.12
+	gopkg.in/yaml.v2 v2.2.2
 )
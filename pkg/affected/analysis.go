@@ -0,0 +1,314 @@
+package affected
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/generate"
+	"github.com/vidsy/affected/pkg/glob"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs"
+	"github.com/vidsy/affected/pkg/vcs/git"
+	"golang.org/x/tools/go/packages"
+)
+
+// Analysis holds the result of analysing a module for affected packages
+type Analysis struct {
+	Graph     *module.Graph     // Import graph the analysis was performed on
+	Changed   []string          // Files changed between the two refs, excluding files matching the exclude globs
+	Modified  []*module.Package // Packages with modifications between the two refs, sorted by ID
+	Files     []*module.Package // Synthetic file nodes with modifications, e.g changed protobuf files, sorted by ID
+	Packages  []Package         // Packages affected by the modifications, sorted by ID
+	Errors    []LoadError       // Errors loading packages in the module
+	MaxDepth  int               // Maximum import hops changes propagated, < 1 for no limit
+	Firewalls []Firewall        // Packages whose changes did not propagate to their importers
+}
+
+// Analyse analyses the module for packages affected by direct or indirect changes between the two
+// refs, use PackageOptions to overide defautlt behaviour
+func Analyse(name, a, b string, opts ...PackagesOption) (*Analysis, error) {
+	g, err := git.New()
+	if err != nil {
+		return nil, err
+	}
+
+	o := &PackagesOptions{
+		VCS:              g,
+		GraphConstructor: module.DefaultGraphConstructor(),
+		PackageLoader:    module.DefaultPackageLoader(),
+		IncludeGlobs:     glob.IncludeDefault(),
+		ExcludeGlobs:     glob.ExcludeDefault(),
+		ImportPathLimit:  1,
+		Triggers:         DefaultTriggers(),
+		Protos:           true,
+		Generate:         true,
+		Embeds:           true,
+		ChangeDetails:    true,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	pkgs, err := o.PackageLoader.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Packages in the module, before any packages of modified dependencies are added
+	local := pkgs
+
+	for _, f := range o.Firewalls {
+		if err := f.validate(local); err != nil {
+			return nil, err
+		}
+	}
+
+	// NOTE: There maybe a better way of resolving an absolute go file path to a package import path
+	// But at time of writting I could not find one. Ideally we would resolve package path while
+	// looping over the files which would be more efficient but since I can't figure out how to
+	// resolve a go file path to a package import path this is the best I could come up with.
+
+	dirs := make(map[string]*packages.Package)
+
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) > 0 {
+			dirs[filepath.Dir(pkg.GoFiles[0])] = pkg
+		}
+	}
+
+	// Triggers are matched against every changed file, not just the included files, so only the
+	// exclude globs are applied when asking the VCS for modified files
+	changed, err := o.VCS.ModifiedFiles(a, b, vcs.ModifiedDirectoriesExcludeGlobs(o.ExcludeGlobs...))
+	if err != nil {
+		return nil, err
+	}
+
+	files := changed
+	if len(o.IncludeGlobs) > 0 {
+		files = glob.Include(changed, o.IncludeGlobs...)
+	}
+
+	errs := loadErrors(pkgs...)
+
+	syntax, err := syntaxErrors(dirs, files, errs)
+	if err != nil {
+		return nil, err
+	}
+
+	errs = append(errs, syntax...)
+	if o.Strict && len(errs) > 0 {
+		return nil, LoadErrors(errs)
+	}
+
+	var directives []string
+
+	// Tools changed in go.mod, keyed by package path, to the go.mod file they changed in
+	tools := make(map[string]string)
+
+	modified := newModifications()
+
+	// Change details cost extra diffs, so they are only detected when asked for and supported
+	if d, ok := o.VCS.(vcs.FileChangesDetector); ok && o.ChangeDetails {
+		changes, err := d.FileChanges(a, b)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, change := range changes {
+			modified.changes[change.Path] = change
+		}
+	}
+
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		switch {
+		case strings.Contains(file, "go.mod"):
+			diff, err := diffModfile(o.VCS, o.PackageLoader, a, b, file)
+			if err != nil {
+				return nil, err
+			}
+
+			// Add packages to the packages used to build the import graph
+			pkgs = append(pkgs, diff.Packages...)
+
+			// Add packages to modified packages
+			modified.add(CausePackage, CategoryModule, file, diff.Packages...)
+
+			directives = append(directives, diff.Directives...)
+
+			for _, tool := range diff.Tools {
+				tools[tool] = file
+			}
+		default:
+			modified.add(CausePackage, fileCategory(dirs, file), file, resolveFile(o, dirs, file)...)
+		}
+	}
+
+	if o.Embeds {
+		// Embedded files are matched against every changed file since they are rarely Go files
+		embedded(local, changed, modified)
+	}
+
+	if o.Generate {
+		scan := local
+
+		// Loaders that cache packages, like module.PackageCache, also cache their directives. Without
+		// a cache every Go file has to be read, so unless a tool changed, which can be run from any
+		// package, only the packages in or above a changed file's directory are scanned
+		s, ok := o.PackageLoader.(generate.Scanner)
+		if !ok {
+			s = generate.FileScanner()
+
+			if len(tools) == 0 {
+				scan = enclosing(local, changed)
+			}
+		}
+
+		if err := generated(s, scan, changed, tools, modified); err != nil {
+			return nil, err
+		}
+	}
+
+	// Dependencies on non-Go files are matched against every changed file, like triggers, since
+	// their files are rarely included
+	pkgs, err = depend(pkgs, changed, modified, o.Dependencies...)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Protos {
+		roots := o.ProtoRoots
+		if len(roots) == 0 {
+			root, err := module.Root()
+			if err != nil {
+				return nil, err
+			}
+
+			roots = []string{root}
+		}
+
+		pkgs, err = protos(pkgs, changed, modified, roots...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Build the graph
+	graph := module.NewGraph(pkgs...)
+
+	analysis := &Analysis{
+		Graph:     graph,
+		Changed:   changed,
+		Packages:  affected(graph, o, modified),
+		Errors:    errs,
+		MaxDepth:  o.MaxDepth,
+		Firewalls: o.Firewalls,
+	}
+
+	analysis.Modified, analysis.Files = modifiedPackages(graph, modified)
+
+	// Global triggers affect every package in the module
+	if triggers := fired(o.Triggers, changed, directives); len(triggers) > 0 {
+		all := make([]*module.Package, 0, len(local))
+
+		for _, pkg := range local {
+			if p := graph.Lookup(pkg.ID); p != nil {
+				all = append(all, p)
+			}
+		}
+
+		analysis.Packages = global(analysis.Packages, all, triggers...)
+	}
+
+	return analysis, nil
+}
+
+// modifiedPackages returns the modified packages and, since they are files rather than packages,
+// the modified synthetic file nodes apart, each sorted by ID
+func modifiedPackages(graph *module.Graph, modified *modifications) (pkgs, files []*module.Package) {
+	for _, pkg := range modified.pkgs {
+		switch p := graph.Lookup(pkg.ID); {
+		case p == nil:
+		case p.Synthetic():
+			files = append(files, p)
+		default:
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ID < pkgs[j].ID
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ID < files[j].ID
+	})
+
+	return pkgs, files
+}
+
+// resolveFile resolves a modified file to the packages it modifies. A file modifies the package in
+// its directory, if there is no package in its directory the file can be resolved to every package
+// beneath a group root or, unless it is a Go file, to the package in the nearest ancestor directory.
+func resolveFile(o *PackagesOptions, dirs map[string]*packages.Package, file string) []*packages.Package {
+	dir := filepath.Dir(file)
+
+	if pkg, ok := dirs[dir]; ok {
+		return []*packages.Package{pkg}
+	}
+
+	if root := groupRoot(o.GroupRoots, dir); root != "" {
+		var pkgs []*packages.Package
+
+		for d, pkg := range dirs {
+			if d == root || strings.HasPrefix(d, root+string(filepath.Separator)) {
+				pkgs = append(pkgs, pkg)
+			}
+		}
+
+		return pkgs
+	}
+
+	// A Go file outside a package belongs to a deleted or ignored package, not to an ancestor's
+	if o.NearestPackage && filepath.Ext(file) != ".go" {
+		for d := filepath.Dir(dir); d != filepath.Dir(d); d = filepath.Dir(d) {
+			if pkg, ok := dirs[d]; ok {
+				return []*packages.Package{pkg}
+			}
+		}
+	}
+
+	return nil
+}
+
+// fileCategory returns the category of a file resolved by resolveFile, Go files in a package's
+// directory are Go source and any other file was matched by the include globs
+func fileCategory(dirs map[string]*packages.Package, file string) CauseCategory {
+	if _, ok := dirs[filepath.Dir(file)]; ok && filepath.Ext(file) == ".go" {
+		return CategoryGo
+	}
+
+	return CategoryInclude
+}
+
+// groupRoot returns the nearest directory, starting at dir and walking up through its ancestors,
+// that matches one of the group root globs
+func groupRoot(globs []string, dir string) string {
+	if len(globs) == 0 {
+		return ""
+	}
+
+	for d := dir; d != filepath.Dir(d); d = filepath.Dir(d) {
+		if len(glob.Include([]string{d}, globs...)) > 0 {
+			return d
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,100 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestResolveFile(t *testing.T) {
+	dirs := map[string]*packages.Package{
+		"/repo/services/foo":     {ID: "foo.com/services/foo"},
+		"/repo/services/foo/api": {ID: "foo.com/services/foo/api"},
+		"/repo/services/bar":     {ID: "foo.com/services/bar"},
+		"/repo/pkg/log":          {ID: "foo.com/pkg/log"},
+	}
+
+	testCases := map[string]struct {
+		opts     PackagesOptions
+		file     string
+		expected []string
+	}{
+		"PackageDirectory": {
+			file:     "/repo/services/foo/api/config.json",
+			expected: []string{"foo.com/services/foo/api"},
+		},
+		"NoPackageDirectory": {
+			file: "/repo/services/foo/config/x.json",
+		},
+		"NearestPackage": {
+			opts:     PackagesOptions{NearestPackage: true},
+			file:     "/repo/services/foo/config/x.json",
+			expected: []string{"foo.com/services/foo"},
+		},
+		"NearestPackageGoFile": {
+			opts: PackagesOptions{NearestPackage: true},
+			file: "/repo/services/foo/deleted/deleted.go",
+		},
+		"NearestPackageNotFound": {
+			opts: PackagesOptions{NearestPackage: true},
+			file: "/repo/docs/index.md",
+		},
+		"GroupRoot": {
+			opts:     PackagesOptions{GroupRoots: []string{"/**/services/*"}},
+			file:     "/repo/services/foo/config/x.json",
+			expected: []string{"foo.com/services/foo", "foo.com/services/foo/api"},
+		},
+		"GroupRootBeforeNearestPackage": {
+			opts:     PackagesOptions{NearestPackage: true, GroupRoots: []string{"/**/services/*"}},
+			file:     "/repo/services/foo/config/x.json",
+			expected: []string{"foo.com/services/foo", "foo.com/services/foo/api"},
+		},
+		"GroupRootNotMatched": {
+			opts: PackagesOptions{GroupRoots: []string{"/**/services/*"}},
+			file: "/repo/docs/index.md",
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var ids []string
+			for _, pkg := range resolveFile(&tc.opts, dirs, tc.file) {
+				ids = append(ids, pkg.ID)
+			}
+
+			assert.ElementsMatch(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestModifiedPackages(t *testing.T) {
+	graph := module.NewGraph(
+		&packages.Package{ID: "foo.com/pkg/db", Imports: map[string]*packages.Package{module.SyntheticPrefix + "migrations": {ID: module.SyntheticPrefix + "migrations"}}},
+		&packages.Package{ID: "foo.com/cmd/a"},
+		module.NewSyntheticPackage("migrations"),
+	)
+
+	modified := newModifications()
+	modified.add(CausePackage, CategoryDependency, "/repo/migrations/1.sql", &packages.Package{ID: module.SyntheticPrefix + "migrations"})
+	modified.add(CausePackage, CategoryGo, "/repo/pkg/db/db.go", &packages.Package{ID: "foo.com/pkg/db"})
+	modified.add(CausePackage, CategoryGo, "/repo/cmd/a/main.go", &packages.Package{ID: "foo.com/cmd/a"})
+	modified.add(CausePackage, CategoryModule, "/repo/go.mod", &packages.Package{ID: "bar.com/not/in/graph"})
+
+	pkgs, files := modifiedPackages(graph, modified)
+
+	assert.Equal(t, []*module.Package{graph.Lookup("foo.com/cmd/a"), graph.Lookup("foo.com/pkg/db")}, pkgs)
+	assert.Equal(t, []*module.Package{graph.Lookup(module.SyntheticPrefix + "migrations")}, files)
+
+	// Synthetic file nodes are not ranked as modified packages
+	analysis := &Analysis{Graph: graph, Modified: pkgs, Files: files}
+
+	for _, i := range analysis.Impact(nil) {
+		assert.False(t, i.Package.Synthetic())
+	}
+}
@@ -0,0 +1,80 @@
+package affected
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// budgetContributors is the number of modified packages reported as contributing most to an
+// exceeded budget
+const budgetContributors = 5
+
+// A Budget limits the blast radius of the analysed changes
+type Budget struct {
+	Packages int // Maximum number of affected packages, < 1 for no limit
+	Groups   int // Maximum number of affected groups, < 1 for no limit
+}
+
+// A BudgetError is returned when the affected packages or groups exceed a budget
+type BudgetError struct {
+	Budget       Budget
+	Packages     int     // Number of affected packages
+	Groups       int     // Number of affected groups
+	Contributors Impacts // Modified packages that contributed most, largest impact first
+}
+
+func (e *BudgetError) Error() string {
+	var exceeded []string
+
+	if e.Budget.Packages > 0 && e.Packages > e.Budget.Packages {
+		exceeded = append(exceeded, fmt.Sprintf("%d packages affected, budget is %d", e.Packages, e.Budget.Packages))
+	}
+
+	if e.Budget.Groups > 0 && e.Groups > e.Budget.Groups {
+		exceeded = append(exceeded, fmt.Sprintf("%d groups affected, budget is %d", e.Groups, e.Budget.Groups))
+	}
+
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, "affected budget exceeded: %s", strings.Join(exceeded, ", "))
+
+	if len(e.Contributors) == 0 {
+		return w.String()
+	}
+
+	fmt.Fprint(w, "\nmodified packages contributing most:")
+
+	for _, i := range e.Contributors {
+		fmt.Fprintf(w, "\n  %s: %d packages", i.Package.ID, i.Packages)
+
+		if i.Groups > 0 {
+			fmt.Fprintf(w, ", %d groups", i.Groups)
+		}
+	}
+
+	return w.String()
+}
+
+// Check checks the affected packages, and groups if fn is not nil, are within the budget. If the
+// budget is exceeded a *BudgetError is returned listing the modified packages that contributed most.
+func (a *Analysis) Check(b Budget, fn GroupFunc) error {
+	e := &BudgetError{
+		Budget:   b,
+		Packages: len(a.Packages),
+	}
+
+	if fn != nil {
+		e.Groups = len(GroupPackages(fn, a.Packages...))
+	}
+
+	if (b.Packages < 1 || e.Packages <= b.Packages) && (fn == nil || b.Groups < 1 || e.Groups <= b.Groups) {
+		return nil
+	}
+
+	e.Contributors = a.Impact(fn)
+	if len(e.Contributors) > budgetContributors {
+		e.Contributors = e.Contributors[:budgetContributors]
+	}
+
+	return e
+}
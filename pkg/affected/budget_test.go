@@ -0,0 +1,69 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestAnalysisCheck(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	modified := newModifications()
+	modified.add(CausePackage, CategoryGo, "", &packages.Package{ID: "foo.com/pkg/log"}, &packages.Package{ID: "foo.com/cmd/c"})
+
+	analysis := &Analysis{
+		Graph:    graph,
+		Modified: []*module.Package{graph.Lookup("foo.com/cmd/c"), graph.Lookup("foo.com/pkg/log")},
+		Packages: affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified),
+	}
+
+	testCases := map[string]struct {
+		budget   Budget
+		fn       GroupFunc
+		expected string
+	}{
+		"WithinBudget": {
+			budget: Budget{Packages: 5, Groups: 2},
+			fn:     GroupByPkgAfterPrefix("foo.com", 0),
+		},
+		"IgnoresGroupBudgetWithoutGroups": {
+			budget: Budget{Groups: 1},
+		},
+		"ExceedsPackageBudget": {
+			budget: Budget{Packages: 4},
+			expected: `affected budget exceeded: 5 packages affected, budget is 4
+modified packages contributing most:
+  foo.com/pkg/log: 4 packages
+  foo.com/cmd/c: 1 packages`,
+		},
+		"ExceedsGroupBudget": {
+			budget: Budget{Packages: 5, Groups: 1},
+			fn:     GroupByPkgAfterPrefix("foo.com", 0),
+			expected: `affected budget exceeded: 2 groups affected, budget is 1
+modified packages contributing most:
+  foo.com/pkg/log: 4 packages, 2 groups
+  foo.com/cmd/c: 1 packages, 1 groups`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := analysis.Check(tc.budget, tc.fn)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.IsType(t, &BudgetError{}, err)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
@@ -1,9 +1,100 @@
 package affected
 
-import "github.com/vidsy/affected/pkg/module"
+import (
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs"
+)
+
+// CauseKind is the kind of change that caused a package to be affected
+type CauseKind string
+
+// Cause kinds
+const (
+	CausePackage  CauseKind = "package"  // A package, or a package it imports, has modifications
+	CauseGlobal   CauseKind = "global"   // A global trigger fired, affecting every package
+	CauseProto    CauseKind = "proto"    // A protobuf file the package is generated from, or one it imports, has changed
+	CauseGenerate CauseKind = "generate" // An input to, or the tool run by, one of the package's go:generate directives has changed
+)
+
+// CauseCategory is the category of change that modified a package
+type CauseCategory string
+
+// Cause categories, most significant first
+const (
+	CategoryModule     CauseCategory = "module"     // A required module's version changed in go.mod
+	CategoryGo         CauseCategory = "go"         // A Go source file in the package changed
+	CategoryEmbed      CauseCategory = "embed"      // A file the package embeds with go:embed changed
+	CategoryInclude    CauseCategory = "include"    // A file matching the include globs changed in or beneath the package
+	CategoryDependency CauseCategory = "dependency" // A file the package is mapped to by a configured dependency changed
+	CategoryProto      CauseCategory = "proto"      // A protobuf file the package is generated from changed
+	CategoryGenerate   CauseCategory = "generate"   // An input to, or the tool run by, a go:generate directive changed
+	CategoryTrigger    CauseCategory = "trigger"    // A global trigger fired
+)
+
+// categories holds the cause categories, most significant first
+var categories = []CauseCategory{
+	CategoryModule,
+	CategoryGo,
+	CategoryEmbed,
+	CategoryInclude,
+	CategoryDependency,
+	CategoryProto,
+	CategoryGenerate,
+	CategoryTrigger,
+}
+
+// precedes reports whether the category is more significant than another, any category is more
+// significant than none
+func (c CauseCategory) precedes(other CauseCategory) bool {
+	for _, category := range categories {
+		switch category {
+		case other:
+			return false
+		case c:
+			return true
+		}
+	}
+
+	return false
+}
+
+// A FileChange is a changed file that modified a package
+type FileChange struct {
+	vcs.FileChange
+
+	Category CauseCategory `json:"category"` // Category of the change
+}
 
 // Cause is why a package has been marked as affected
 type Cause struct {
-	Package    *module.Package   // The package that has modififcations
-	ImportPath module.ImportPath // The import graph to that package
+	Kind        CauseKind           // The kind of change
+	Package     *module.Package     // The package that has modififcations
+	ImportPath  module.ImportPath   // The shortest import graph to that package
+	ImportPaths []module.ImportPath // Distinct import graphs to that package when more than one is requested
+	Routes      uint64              // Number of distinct import graphs to that package
+	Trigger     string              // The global trigger that fired, for global causes
+	Files       []string            // The changed files that modified the package
+	Changes     []FileChange        // How each of the files changed, in file order
+	Category    CauseCategory       // Most significant category of the changes that modified the package
+	Stopped     bool                // A firewall stopped the change propagating to some or all of the package's importers
+}
+
+// Distance returns the number of import hops between the affected package and the modified
+// package, a package's own modifications and global triggers are zero hops away
+func (c Cause) Distance() int {
+	return distance(c.ImportPath)
+}
+
+// distance returns the number of import hops along an import path, synthetic nodes are not
+// packages so importing one is not a hop
+func distance(path module.ImportPath) int {
+	var hops int
+
+	for i, pkg := range path {
+		if i > 0 && !pkg.Synthetic() {
+			hops++
+		}
+	}
+
+	return hops
 }
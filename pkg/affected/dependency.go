@@ -0,0 +1,92 @@
+package affected
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/glob"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+// A Dependency declares that packages depend upon non-Go files, such as SQL migrations, OpenAPI
+// specs or shared fixtures. Each dependency is added to the import graph as a synthetic node
+// imported by its packages, so a change to a matching file affects the packages and their importers.
+type Dependency struct {
+	Name     string   // Name of the synthetic node, defaults to the first glob
+	Globs    []string // File name globs of the files the packages depend upon
+	Packages []string // Package import paths, a path ending in /... matches the package and those beneath it
+}
+
+// node returns the raw synthetic package for the dependency
+func (d Dependency) node() *packages.Package {
+	name := d.Name
+	if name == "" && len(d.Globs) > 0 {
+		name = d.Globs[0]
+	}
+
+	return module.NewSyntheticPackage(name)
+}
+
+// matches reports whether any of the files match the dependency's globs
+func (d Dependency) matches(files []string) []string {
+	if len(d.Globs) == 0 {
+		return nil
+	}
+
+	return glob.Include(files, d.Globs...)
+}
+
+// dependents returns the IDs of the packages that match the dependency's packages, it is an error
+// for a package path to match no packages
+func (d Dependency) dependents(pkgs []*packages.Package) ([]string, error) {
+	var ids []string
+
+	for _, path := range d.Packages {
+		prefix := strings.TrimSuffix(path, "/...")
+		n := len(ids)
+
+		for _, pkg := range pkgs {
+			if pkg.ID == prefix || (prefix != path && strings.HasPrefix(pkg.ID, prefix+"/")) {
+				ids = append(ids, pkg.ID)
+			}
+		}
+
+		if len(ids) == n {
+			return nil, fmt.Errorf("dependency %s: no packages match %s", d.node().Name, path)
+		}
+	}
+
+	return ids, nil
+}
+
+// depend adds the synthetic node for each dependency to the packages, the synthetic nodes of
+// dependencies with changed files are added to the modifications
+func depend(pkgs []*packages.Package, changed []string, modified *modifications, deps ...Dependency) ([]*packages.Package, error) {
+	if len(deps) == 0 {
+		return pkgs, nil
+	}
+
+	edges := make(map[string][]*packages.Package)
+	nodes := make([]*packages.Package, 0, len(deps))
+
+	for _, d := range deps {
+		ids, err := d.dependents(pkgs)
+		if err != nil {
+			return nil, err
+		}
+
+		node := d.node()
+		nodes = append(nodes, node)
+
+		for _, id := range ids {
+			edges[id] = append(edges[id], node)
+		}
+
+		for _, file := range d.matches(changed) {
+			modified.add(CausePackage, CategoryDependency, file, node)
+		}
+	}
+
+	return append(module.Depend(pkgs, edges), nodes...), nil
+}
@@ -0,0 +1,64 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestDepend(t *testing.T) {
+	raw := testPackages(testImports)
+
+	in := make([]*packages.Package, 0, len(raw))
+	for _, pkg := range raw {
+		in = append(in, pkg)
+	}
+
+	modified := newModifications()
+
+	pkgs, err := depend(in, []string{"/repo/migrations/billing/001.sql", "/repo/README.md"}, modified,
+		Dependency{Name: "billing migrations", Globs: []string{"/**/migrations/billing/*.sql"}, Packages: []string{"foo.com/pkg/svc"}},
+		Dependency{Globs: []string{"/**/openapi.yaml"}, Packages: []string{"foo.com/cmd/..."}})
+	require.NoError(t, err)
+	require.Len(t, modified.pkgs, 1)
+
+	// The given packages are not modified
+	assert.Len(t, raw["foo.com/pkg/svc"].Imports, 2)
+
+	graph := module.NewGraph(pkgs...)
+
+	spec := graph.Lookup(module.SyntheticPrefix + "/**/openapi.yaml")
+	require.NotNil(t, spec)
+	assert.True(t, spec.Synthetic())
+	assert.Len(t, spec.Parents, 3)
+
+	affected := affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified)
+
+	paths := make(map[string][]string)
+
+	for _, pkg := range affected {
+		require.Len(t, pkg.Causes, 1)
+		assert.Equal(t, []string{"/repo/migrations/billing/001.sql"}, pkg.Causes[0].Files)
+
+		for _, p := range pkg.Causes[0].ImportPath {
+			paths[pkg.ID] = append(paths[pkg.ID], p.ID)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"foo.com/pkg/svc": {"foo.com/pkg/svc", "file:billing migrations"},
+		"foo.com/cmd/a":   {"foo.com/cmd/a", "foo.com/pkg/svc", "file:billing migrations"},
+	}, paths)
+}
+
+func TestDependUnknownPackage(t *testing.T) {
+	pkgs := []*packages.Package{{ID: "foo.com/cmd/a"}}
+
+	for _, path := range []string{"foo.com/cmd/b", "foo.com/pkg/..."} {
+		_, err := depend(pkgs, nil, nil, Dependency{Globs: []string{"*.sql"}, Packages: []string{path}})
+		assert.Error(t, err)
+	}
+}
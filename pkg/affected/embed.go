@@ -0,0 +1,32 @@
+package affected
+
+import (
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// embedded adds the packages that embed a changed file with go:embed to the modifications
+func embedded(pkgs []*packages.Package, changed []string, modified *modifications) {
+	for _, pkg := range pkgs {
+		for _, file := range changed {
+			if embeds(pkg, file) {
+				modified.add(CausePackage, CategoryEmbed, file, pkg)
+			}
+		}
+	}
+}
+
+// embeds reports whether the package embeds the file, a pattern embeds the files it matches and
+// every file beneath the directories it matches
+func embeds(pkg *packages.Package, file string) bool {
+	for _, pattern := range pkg.EmbedPatterns {
+		for f := file; f != filepath.Dir(f); f = filepath.Dir(f) {
+			if ok, _ := filepath.Match(pattern, f); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
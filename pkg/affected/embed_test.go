@@ -0,0 +1,60 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestEmbedded(t *testing.T) {
+	pkgs := []*packages.Package{
+		{ID: "foo.com/web", EmbedPatterns: []string{"/repo/web/static", "/repo/web/templates/*.html"}},
+		{ID: "foo.com/docs", EmbedPatterns: []string{"/repo/docs/index.md"}},
+		{ID: "foo.com/api"},
+	}
+
+	testCases := map[string]struct {
+		changed  []string
+		expected map[string][]string
+	}{
+		"FileBeneathDirectory": {
+			changed:  []string{"/repo/web/static/css/site.css"},
+			expected: map[string][]string{"foo.com/web": {"/repo/web/static/css/site.css"}},
+		},
+		"FileMatchingGlob": {
+			changed:  []string{"/repo/web/templates/index.html", "/repo/web/templates/index.txt"},
+			expected: map[string][]string{"foo.com/web": {"/repo/web/templates/index.html"}},
+		},
+		"File": {
+			changed:  []string{"/repo/docs/index.md", "/repo/docs/other.md"},
+			expected: map[string][]string{"foo.com/docs": {"/repo/docs/index.md"}},
+		},
+		"NotEmbedded": {
+			changed:  []string{"/repo/web/static.go", "/repo/api/static/a.css"},
+			expected: map[string][]string{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			modified := newModifications()
+			embedded(pkgs, tc.changed, modified)
+
+			actual := make(map[string][]string)
+
+			for _, pkg := range modified.pkgs {
+				mod := modified.get(pkg.ID)
+
+				assert.Equal(t, CategoryEmbed, mod.Category)
+				actual[pkg.ID] = mod.Files
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
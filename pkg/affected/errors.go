@@ -0,0 +1,110 @@
+package affected
+
+import (
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A LoadError is an error encountered while loading a package in the analysed module, packages
+// that fail to load may be missing import edges and under-report the affected packages
+type LoadError struct {
+	Package string `json:"package"`       // Package that failed to load
+	Pos     string `json:"pos,omitempty"` // Position of the error, if known
+	Msg     string `json:"msg"`           // Error message
+}
+
+func (e LoadError) Error() string {
+	if e.Pos != "" {
+		return fmt.Sprintf("%s: %s: %s", e.Package, e.Pos, e.Msg)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Package, e.Msg)
+}
+
+// LoadErrors is returned in strict mode when one or more packages failed to load
+type LoadErrors []LoadError
+
+func (e LoadErrors) Error() string {
+	msgs := make([]string, len(e))
+
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("failed to load packages (%d errors):\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// loadErrors collects the errors of the given packages
+func loadErrors(pkgs ...*packages.Package) []LoadError {
+	var errs []LoadError
+
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			errs = append(errs, LoadError{
+				Package: pkg.ID,
+				Pos:     err.Pos,
+				Msg:     err.Msg,
+			})
+		}
+	}
+
+	return errs
+}
+
+// syntaxErrors parses the changed Go files in the packages' directories for syntax errors. go list
+// only parses the package clause and imports, so the default loader does not report errors further
+// into a file. Files with a known error are skipped so errors are not reported twice.
+func syntaxErrors(dirs map[string]*packages.Package, files []string, known []LoadError) ([]LoadError, error) {
+	var errs []LoadError
+
+	fset := token.NewFileSet()
+
+	for _, file := range files {
+		pkg, ok := dirs[filepath.Dir(file)]
+		if !ok || filepath.Ext(file) != ".go" || hasError(known, file) {
+			continue
+		}
+
+		_, err := parser.ParseFile(fset, file, nil, 0)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		list, ok := err.(scanner.ErrorList)
+		if !ok {
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		for _, e := range list {
+			errs = append(errs, LoadError{
+				Package: pkg.ID,
+				Pos:     e.Pos.String(),
+				Msg:     e.Msg,
+			})
+		}
+	}
+
+	return errs, nil
+}
+
+// hasError reports whether any of the errors are positioned in the file
+func hasError(errs []LoadError, file string) bool {
+	for _, e := range errs {
+		if strings.HasPrefix(e.Pos, file+":") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package affected
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSyntaxErrors(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-syntax")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	write := func(name, src string) string {
+		path := filepath.Join(root, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+
+		return path
+	}
+
+	valid := write("valid.go", "package a\n\nfunc A() {}\n")
+	broken := write("broken.go", "package a\n\nfunc B() {\n")
+	known := write("known.go", "package a\n\nfunc C( {}\n")
+	other := write("README.md", "func (\n")
+
+	dirs := map[string]*packages.Package{root: {ID: "foo.com/a"}}
+
+	errs, err := syntaxErrors(
+		dirs,
+		[]string{valid, broken, known, other, filepath.Join(root, "deleted.go"), "/elsewhere/x.go"},
+		[]LoadError{{Package: "foo.com/a", Pos: known + ":3:9", Msg: "expected type"}},
+	)
+	require.NoError(t, err)
+
+	require.Len(t, errs, 1)
+	assert.Equal(t, "foo.com/a", errs[0].Package)
+	assert.Equal(t, broken+":3:12", errs[0].Pos)
+	assert.Equal(t, "expected '}', found 'EOF'", errs[0].Msg)
+}
@@ -0,0 +1,121 @@
+package affected
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+// A Firewall is a propagation boundary, a firewall package is affected by its own changes and the
+// changes of the packages it imports but those changes do not propagate to its importers. Useful
+// for packages such as generated API clients with stable contracts or logging facades.
+type Firewall struct {
+	Packages  []string // Package import paths, a path ending in /... matches the package and those beneath it
+	Importers []string // Import paths of the importers changes stop at, matched like Packages, empty stops them for every importer
+}
+
+// matches reports whether the package is one of the firewall's packages
+func (f Firewall) matches(id string) bool {
+	for _, path := range f.Packages {
+		if matchesPath(path, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blocks reports whether changes to the imported package stop before reaching the importer
+func (f Firewall) blocks(importer, imported *module.Package) bool {
+	if !f.matches(imported.ID) {
+		return false
+	}
+
+	if len(f.Importers) == 0 {
+		return true
+	}
+
+	for _, path := range f.Importers {
+		if matchesPath(path, importer.ID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate checks each of the firewall's package paths matches a package, it is an error for a
+// package path to match no packages
+func (f Firewall) validate(pkgs []*packages.Package) error {
+	for _, path := range f.Packages {
+		var found bool
+
+		for _, pkg := range pkgs {
+			if found = matchesPath(path, pkg.ID); found {
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("firewall: no packages match %s", path)
+		}
+	}
+
+	return nil
+}
+
+// firewalled returns an edge function that only follows the imports that are not blocked by a
+// firewall, if there are no firewalls the return value will be nil
+func firewalled(firewalls []Firewall) module.EdgeFunc {
+	if len(firewalls) == 0 {
+		return nil
+	}
+
+	return func(importer, imported *module.Package) bool {
+		return !blocked(firewalls, importer, imported)
+	}
+}
+
+// blocked reports whether any firewall stops changes to the imported package reaching the importer
+func blocked(firewalls []Firewall, importer, imported *module.Package) bool {
+	for _, f := range firewalls {
+		if f.blocks(importer, imported) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// boundary returns the first package along the import path whose changes are stopped by a
+// firewall, if changes propagate along the whole path the return value will be nil
+func boundary(firewalls []Firewall, path module.ImportPath) *module.Package {
+	for i := len(path) - 1; i > 0; i-- {
+		if blocked(firewalls, path[i-1], path[i]) {
+			return path[i]
+		}
+	}
+
+	return nil
+}
+
+// stopped reports whether changes to the package stop before reaching any of its importers
+func stopped(firewalls []Firewall, pkg *module.Package) bool {
+	for _, parent := range pkg.Parents {
+		if blocked(firewalls, parent, pkg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesPath reports whether the package ID matches the import path, a path ending in /...
+// matches the package and those beneath it
+func matchesPath(path, id string) bool {
+	prefix := strings.TrimSuffix(path, "/...")
+
+	return id == prefix || (prefix != path && strings.HasPrefix(id, prefix+"/"))
+}
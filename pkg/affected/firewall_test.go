@@ -0,0 +1,114 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestAffectedFirewalls(t *testing.T) {
+	testCases := map[string]struct {
+		firewalls []Firewall
+		modified  []string
+		expected  map[string][]bool
+	}{
+		"StopsAtFirewallForEveryImporter": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/log"}}},
+			modified:  []string{"foo.com/pkg/util"},
+			expected: map[string][]bool{
+				"foo.com/pkg/util": {false},
+				"foo.com/pkg/log":  {true},
+				"foo.com/pkg/svc":  {false},
+				"foo.com/cmd/a":    {false},
+			},
+		},
+		"StopsAtFirewallForImporterPaths": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/log"}, Importers: []string{"foo.com/cmd/..."}}},
+			modified:  []string{"foo.com/pkg/log"},
+			expected: map[string][]bool{
+				"foo.com/pkg/log": {true},
+				"foo.com/pkg/svc": {false},
+				"foo.com/cmd/a":   {false},
+			},
+		},
+		"StopsAtFirewallForImporterPackage": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/svc"}, Importers: []string{"foo.com/cmd/a"}}},
+			modified:  []string{"foo.com/pkg/util"},
+			expected: map[string][]bool{
+				"foo.com/pkg/util": {false},
+				"foo.com/pkg/log":  {false},
+				"foo.com/pkg/svc":  {true},
+				"foo.com/cmd/b":    {false},
+			},
+		},
+		"ImporterPathIsNotPrefix": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/log"}, Importers: []string{"foo.com/cmd"}}},
+			modified:  []string{"foo.com/pkg/log"},
+			expected: map[string][]bool{
+				"foo.com/pkg/log": {false},
+				"foo.com/pkg/svc": {false},
+				"foo.com/cmd/a":   {false},
+				"foo.com/cmd/b":   {false},
+			},
+		},
+		"ReportsEveryCause": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/log"}}},
+			modified:  []string{"foo.com/pkg/log", "foo.com/pkg/util"},
+			expected: map[string][]bool{
+				"foo.com/pkg/util": {false},
+				"foo.com/pkg/log":  {true, true},
+				"foo.com/pkg/svc":  {false},
+				"foo.com/cmd/a":    {false},
+			},
+		},
+		"ReportsModifiedFirewallPackage": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/..."}}},
+			modified:  []string{"foo.com/pkg/util"},
+			expected: map[string][]bool{
+				"foo.com/pkg/util": {true},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			graph := testGraph(testPackages(testImports))
+
+			modified := newModifications()
+			for _, id := range tc.modified {
+				modified.add(CausePackage, CategoryGo, "", &packages.Package{ID: id})
+			}
+
+			actual := make(map[string][]bool)
+
+			for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: 0, Firewalls: tc.firewalls}, modified) {
+				for _, cause := range pkg.Causes {
+					for i := 1; i < len(cause.ImportPath); i++ {
+						assert.False(t, blocked(tc.firewalls, cause.ImportPath[i-1], cause.ImportPath[i]))
+					}
+
+					actual[pkg.ID] = append(actual[pkg.ID], cause.Stopped)
+				}
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFirewallValidate(t *testing.T) {
+	pkgs := testPackages(testImports)
+	in := make([]*packages.Package, 0, len(pkgs))
+
+	for _, pkg := range pkgs {
+		in = append(in, pkg)
+	}
+
+	assert.NoError(t, Firewall{Packages: []string{"foo.com/pkg/log", "foo.com/cmd/..."}}.validate(in))
+	assert.EqualError(t, Firewall{Packages: []string{"foo.com/pkg/missing"}}.validate(in), "firewall: no packages match foo.com/pkg/missing")
+}
@@ -0,0 +1,83 @@
+package affected
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/vidsy/affected/pkg/generate"
+	"github.com/vidsy/affected/pkg/glob"
+	"golang.org/x/tools/go/packages"
+)
+
+// generated marks packages as modified when an input to one of their go:generate directives has
+// changed, or when a tool one of their directives runs was added to or changed version in go.mod.
+// tools maps the package path of each changed tool to the go.mod file it changed in.
+func generated(s generate.Scanner, pkgs []*packages.Package, changed []string, tools map[string]string, modified *modifications) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(tools))
+	for tool := range tools {
+		names = append(names, tool)
+	}
+
+	sort.Strings(names)
+
+	for _, pkg := range pkgs {
+		directives, err := s.Directives(pkg)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range directives {
+			if len(d.Inputs) > 0 {
+				for _, file := range glob.Include(changed, d.Inputs...) {
+					modified.add(CauseGenerate, CategoryGenerate, file, pkg)
+				}
+			}
+
+			for _, tool := range names {
+				if d.Uses(tool) {
+					modified.add(CauseGenerate, CategoryGenerate, tools[tool], pkg)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// enclosing returns the packages whose directory holds a changed file, or is an ancestor of a
+// changed file's directory
+func enclosing(pkgs []*packages.Package, changed []string) []*packages.Package {
+	dirs := make(map[string]struct{})
+
+	for _, file := range changed {
+		for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+			if _, ok := dirs[dir]; ok {
+				break
+			}
+
+			dirs[dir] = struct{}{}
+
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	var out []*packages.Package
+
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+
+		if _, ok := dirs[filepath.Dir(pkg.GoFiles[0])]; ok {
+			out = append(out, pkg)
+		}
+	}
+
+	return out
+}
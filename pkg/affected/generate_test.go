@@ -0,0 +1,114 @@
+package affected
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/generate"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGenerated(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-generate")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	write := func(name, src string) string {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+
+		return path
+	}
+
+	pkgs := []*packages.Package{
+		{ID: "foo.com/mocks", GoFiles: []string{write("mocks/gen.go", `package mocks
+
+//go:generate go tool mockgen -source=../api/client.go
+`)}},
+		{ID: "foo.com/db", GoFiles: []string{write("db/gen.go", `package db
+
+//affected:inputs ../migrations/*.sql
+//go:generate go run ./gen
+`)}},
+		{ID: "foo.com/api", GoFiles: []string{write("api/client.go", "package api\n")}},
+	}
+
+	modfile := filepath.Join(root, "go.mod")
+
+	testCases := map[string]struct {
+		changed  []string
+		tools    map[string]string
+		expected map[string][]string
+	}{
+		"InputChanged": {
+			changed: []string{
+				filepath.Join(root, "api", "client.go"),
+				filepath.Join(root, "migrations", "001.sql"),
+				filepath.Join(root, "README.md"),
+			},
+			expected: map[string][]string{
+				"foo.com/mocks": {filepath.Join(root, "api", "client.go")},
+				"foo.com/db":    {filepath.Join(root, "migrations", "001.sql")},
+			},
+		},
+		"ToolChanged": {
+			changed: []string{modfile},
+			tools: map[string]string{
+				"go.uber.org/mock/mockgen":        modfile,
+				"golang.org/x/tools/cmd/stringer": modfile,
+			},
+			expected: map[string][]string{
+				"foo.com/mocks": {modfile},
+			},
+		},
+		"NothingChanged": {
+			expected: map[string][]string{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		// Subtests are not run in parallel as the temporary directory is removed when the test returns
+		t.Run(name, func(t *testing.T) {
+			modified := newModifications()
+			require.NoError(t, generated(generate.FileScanner(), pkgs, tc.changed, tc.tools, modified))
+
+			actual := make(map[string][]string)
+
+			for _, pkg := range modified.pkgs {
+				mod := modified.get(pkg.ID)
+
+				assert.Equal(t, CauseGenerate, mod.Kind)
+				actual[pkg.ID] = mod.Files
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestEnclosing(t *testing.T) {
+	pkgs := []*packages.Package{
+		{ID: "foo.com/db", GoFiles: []string{"/repo/db/gen.go"}},
+		{ID: "foo.com/db/queries", GoFiles: []string{"/repo/db/queries/gen.go"}},
+		{ID: "foo.com/mocks", GoFiles: []string{"/repo/mocks/gen.go"}},
+		{ID: "foo.com/empty"},
+	}
+
+	actual := enclosing(pkgs, []string{"/repo/db/queries/sql/users.sql", "/repo/db/schema.sql"})
+
+	ids := make([]string, len(actual))
+	for i, pkg := range actual {
+		ids[i] = pkg.ID
+	}
+
+	assert.Equal(t, []string{"foo.com/db", "foo.com/db/queries"}, ids)
+}
@@ -22,7 +22,7 @@ func (g Group) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// Groups holds groups of grouped affected packages
+// Groups holds groups of grouped affected packages, sorted by name
 type Groups []Group
 
 func (g Groups) String() string {
@@ -39,15 +39,7 @@ func (g Groups) String() string {
 		fmt.Fprintln(w, strings.Repeat("-", len(title)))
 
 		for _, pkg := range group.Packages {
-			fmt.Fprintln(w, "- Package: ", pkg.ID)
-
-			for _, cause := range pkg.Causes {
-				fmt.Fprintln(w, " - Caused By:", cause.Package.ID)
-
-				for i, pkg := range cause.ImportPath {
-					fmt.Fprintln(w, fmt.Sprintf("  %s %s", strings.Repeat(">", i), pkg.ID))
-				}
-			}
+			writePackage(w, pkg)
 		}
 	}
 
@@ -70,12 +62,12 @@ func GroupByPkgPrefix(prefix string) GroupFunc {
 // - foo.com/pkg/a
 // - foo.com/pkg/b
 // If the given prefix is foo.com/pkg and the element given is 0
-// If the number of path elements after the prefix is < the given n the package will not be grouped
+// If there is no n path element after the prefix the package will not be grouped
 func GroupByPkgAfterPrefix(prefix string, n int) GroupFunc {
 	return func(pkg *Package) (string, bool) {
 		if strings.HasPrefix(pkg.ID, prefix) {
 			parts := strings.Split(strings.TrimLeft(strings.TrimPrefix(pkg.ID, prefix), "/"), "/")
-			if len(parts) >= n {
+			if len(parts) > n {
 				return strings.Join([]string{prefix, parts[n]}, "/"), true
 			}
 		}
@@ -87,7 +79,8 @@ func GroupByPkgAfterPrefix(prefix string, n int) GroupFunc {
 // A GroupFunc determines a packages group name and if it should be grouped
 type GroupFunc func(*Package) (string, bool)
 
-// GroupPackages groups affected packages into groups determined by the GroupFunc
+// GroupPackages groups affected packages into groups determined by the GroupFunc, groups are sorted
+// by name and their packages by ID
 func GroupPackages(fn GroupFunc, pkgs ...Package) Groups {
 	gm := make(map[string]*Group)
 
@@ -114,5 +107,7 @@ func GroupPackages(fn GroupFunc, pkgs ...Package) Groups {
 		groups = append(groups, *g)
 	}
 
+	sortGroups(groups)
+
 	return groups
 }
@@ -0,0 +1,58 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGroupByPkgAfterPrefix(t *testing.T) {
+	testCases := map[string]struct {
+		id      string
+		n       int
+		name    string
+		grouped bool
+	}{
+		"GroupsByFirstElement": {
+			id:      "foo.com/services/a/cmd",
+			n:       0,
+			name:    "foo.com/services/a",
+			grouped: true,
+		},
+		"GroupsBySecondElement": {
+			id:      "foo.com/services/a/cmd",
+			n:       1,
+			name:    "foo.com/services/cmd",
+			grouped: true,
+		},
+		"DoesNotGroupPackageOneLevelUnderPrefix": {
+			id: "foo.com/services/a",
+			n:  1,
+		},
+		"DoesNotGroupPrefixPackage": {
+			id: "foo.com/services",
+			n:  1,
+		},
+		"DoesNotGroupOtherPackages": {
+			id: "foo.com/pkg/log",
+			n:  0,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pkg := module.NewGraph(&packages.Package{ID: tc.id}).Lookup(tc.id)
+
+			name, grouped := GroupByPkgAfterPrefix("foo.com/services", tc.n)(&Package{Package: pkg})
+
+			assert.Equal(t, tc.name, name)
+			assert.Equal(t, tc.grouped, grouped)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package affected
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// An Impact is the blast radius of a single package, the packages and groups its changes affect
+type Impact struct {
+	Package  *module.Package   // The package whose changes are measured
+	Packages int               // Packages affected by the package's changes, including itself
+	Groups   int               // Groups with an affected package, zero when packages are not grouped
+	Share    float64           // Share of the packages in the import graph that are affected
+	Chain    module.ImportPath // The longest of the shortest import paths from an affected package to the package, the longest import path with GraphImpact
+}
+
+// MarshalJSON marshals an impact into a json structure
+func (i Impact) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"package":  i.Package.ID,
+		"packages": i.Packages,
+		"groups":   i.Groups,
+		"share":    i.Share,
+		"chain":    i.Chain,
+	})
+}
+
+// Impacts holds the impact of packages, ordered by the number of packages affected, largest first
+type Impacts []Impact
+
+func (im Impacts) String() string {
+	w := new(bytes.Buffer)
+
+	for _, i := range im {
+		fmt.Fprintf(w, "- Package:  %s\n", i.Package.ID)
+		fmt.Fprintf(w, " - Affects: %d packages (%.1f%%)", i.Packages, i.Share*100) // nolint: mnd
+
+		if i.Groups > 0 {
+			fmt.Fprintf(w, ", %d groups", i.Groups)
+		}
+
+		fmt.Fprintln(w)
+
+		if len(i.Chain) > 1 {
+			fmt.Fprintf(w, " - Longest Chain: %d hops\n", distance(i.Chain))
+			writeImportPath(w, i.Chain)
+		}
+	}
+
+	return w.String()
+}
+
+// Impact returns the impact of each modified package, the packages affected by each are found as
+// they were in the analysis so firewalls and the maximum depth are respected. A nil fn does not
+// count groups.
+func (a *Analysis) Impact(fn GroupFunc) Impacts {
+	return impact(a.Graph, fn, firewalled(a.Firewalls), a.MaxDepth, a.Modified...)
+}
+
+// GraphImpact returns the impact of every package in the graph as if each were modified, scoring
+// packages by the number of packages that directly or indirectly import them. Useful for spotting
+// hub packages without a diff. A nil fn does not count groups and a depth of 0 counts importers at
+// any distance.
+//
+// Rather than searching from every package, importers are counted in a single pass over the graph
+// in reverse topological order, each package's importers being the union of its parents' importers,
+// with one pass per hop when the depth is limited. Each package's chain is the longest import path
+// to it within the depth, found in the same pass. An error is returned if the graph contains an
+// import cycle.
+func GraphImpact(g *module.Graph, fn GroupFunc, depth int, firewalls ...Firewall) (Impacts, error) {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	follow := firewalled(firewalls)
+	pkgs := g.Packages()
+
+	index := make(map[*module.Package]int, len(pkgs))
+	for i, pkg := range pkgs {
+		index[pkg] = i
+	}
+
+	// parents returns the importers of a package whose imports are followed
+	parents := func(pkg *module.Package) []*module.Package {
+		if follow == nil {
+			return pkg.Parents
+		}
+
+		var out []*module.Package
+
+		for _, parent := range pkg.Parents {
+			if follow(parent, pkg) {
+				out = append(out, parent)
+			}
+		}
+
+		return out
+	}
+
+	// importers returns, for every package, the packages importing it within one more hop than the
+	// previous layer, or at any distance when unlimited. Synthetic nodes are not packages, so
+	// importing one is not a hop and they are never counted themselves.
+	importers := func(prev []bitset, unlimited bool) []bitset {
+		sets := make([]bitset, len(pkgs))
+
+		for i := len(order) - 1; i >= 0; i-- {
+			pkg := order[i]
+			set := newBitset(len(pkgs))
+
+			if !pkg.Synthetic() {
+				set.add(index[pkg])
+			}
+
+			from := prev
+			if unlimited || pkg.Synthetic() {
+				from = sets
+			}
+
+			for _, parent := range parents(pkg) {
+				if from != nil {
+					set.or(from[index[parent]])
+				}
+			}
+
+			sets[index[pkg]] = set
+		}
+
+		return sets
+	}
+
+	var sets []bitset
+
+	if depth > 0 {
+		for hop := 0; hop <= depth; hop++ {
+			sets = importers(sets, false)
+		}
+	} else {
+		sets = importers(nil, true)
+	}
+
+	chains := longestChains(order, index, parents, depth)
+
+	var total int
+
+	names := make([]string, len(pkgs))
+	grouped := make([]bool, len(pkgs))
+
+	for i, pkg := range pkgs {
+		if pkg.Synthetic() {
+			continue
+		}
+
+		total++
+
+		if fn != nil {
+			names[i], grouped[i] = fn(&Package{Package: pkg})
+		}
+	}
+
+	impacts := make(Impacts, 0, total)
+
+	for i, pkg := range pkgs {
+		if pkg.Synthetic() {
+			continue
+		}
+
+		im := Impact{Package: pkg, Chain: chains[i]}
+		groups := make(map[string]struct{})
+
+		sets[i].each(func(x int) {
+			im.Packages++
+
+			if grouped[x] {
+				groups[names[x]] = struct{}{}
+			}
+		})
+
+		im.Groups = len(groups)
+		im.Share = float64(im.Packages) / float64(total)
+
+		impacts = append(impacts, im)
+	}
+
+	rank(impacts)
+
+	return impacts, nil
+}
+
+// longestChains returns, for every package, the longest import path to it from a package, of at
+// most depth hops when depth is above 0. Paths are found from the top of the graph down, each
+// package extending the longest path to the parent it is imported by.
+func longestChains(order []*module.Package, index map[*module.Package]int, parents func(*module.Package) []*module.Package, depth int) []module.ImportPath {
+	hops := make([]int, len(index))
+	up := make([]*module.Package, len(index))
+
+	// reached records whether a package is reached from a package, rather than only from synthetic
+	// nodes, which do not start a path
+	reached := make([]bool, len(index))
+
+	for i := len(order) - 1; i >= 0; i-- {
+		pkg := order[i]
+		x := index[pkg]
+
+		for _, parent := range parents(pkg) {
+			p := index[parent]
+
+			if reached[p] && (up[x] == nil || hops[p] > hops[index[up[x]]]) {
+				up[x] = parent
+			}
+		}
+
+		reached[x] = !pkg.Synthetic() || up[x] != nil
+
+		if up[x] != nil {
+			hops[x] = hops[index[up[x]]]
+			if !pkg.Synthetic() {
+				hops[x]++
+			}
+		}
+	}
+
+	chains := make([]module.ImportPath, len(index))
+
+	for pkg, x := range index {
+		if pkg.Synthetic() {
+			continue
+		}
+
+		// Walk up from the package while the path stays within the depth, then drop any synthetic
+		// nodes left at its start
+		chain := module.ImportPath{pkg}
+		n := 0
+
+		for p := pkg; up[index[p]] != nil; p = up[index[p]] {
+			if !p.Synthetic() {
+				n++
+			}
+
+			if depth > 0 && n > depth {
+				break
+			}
+
+			chain = append(chain, up[index[p]])
+		}
+
+		for chain[len(chain)-1].Synthetic() {
+			chain = chain[:len(chain)-1]
+		}
+
+		for l, r := 0, len(chain)-1; l < r; l, r = l+1, r-1 {
+			chain[l], chain[r] = chain[r], chain[l]
+		}
+
+		chains[x] = chain
+	}
+
+	return chains
+}
+
+func impact(g *module.Graph, fn GroupFunc, follow module.EdgeFunc, depth int, pkgs ...*module.Package) Impacts {
+	var total int
+
+	for _, pkg := range g.Packages() {
+		if !pkg.Synthetic() {
+			total++
+		}
+	}
+
+	impacts := make(Impacts, 0, len(pkgs))
+
+	for _, pkg := range pkgs {
+		i := Impact{Package: pkg}
+		groups := make(map[string]struct{})
+
+		for _, path := range g.ImportPathsFunc(follow, pkg) {
+			if path[0].Synthetic() || (depth > 0 && distance(path) > depth) {
+				continue
+			}
+
+			i.Packages++
+
+			if i.Chain == nil || distance(path) > distance(i.Chain) {
+				i.Chain = path
+			}
+
+			if fn == nil {
+				continue
+			}
+
+			if name, ok := fn(&Package{Package: path[0]}); ok {
+				groups[name] = struct{}{}
+			}
+		}
+
+		i.Groups = len(groups)
+
+		if total > 0 {
+			i.Share = float64(i.Packages) / float64(total)
+		}
+
+		impacts = append(impacts, i)
+	}
+
+	rank(impacts)
+
+	return impacts
+}
+
+// rank orders impacts by the number of packages affected, then by the number of groups, largest
+// first, then by package ID
+func rank(impacts Impacts) {
+	sort.SliceStable(impacts, func(x, y int) bool {
+		if impacts[x].Packages != impacts[y].Packages {
+			return impacts[x].Packages > impacts[y].Packages
+		}
+
+		if impacts[x].Groups != impacts[y].Groups {
+			return impacts[x].Groups > impacts[y].Groups
+		}
+
+		return impacts[x].Package.ID < impacts[y].Package.ID
+	})
+}
+
+// bitset is a set of package indexes
+type bitset []uint64
+
+func newBitset(n int) bitset {
+	return make(bitset, (n+63)/64) // nolint: mnd
+}
+
+func (b bitset) add(i int) {
+	b[i/64] |= 1 << (uint(i) % 64) // nolint: mnd
+}
+
+func (b bitset) or(o bitset) {
+	for i := range b {
+		b[i] |= o[i]
+	}
+}
+
+// each calls fn with every index in the set, in ascending order
+func (b bitset) each(fn func(int)) {
+	for i, word := range b {
+		for word != 0 {
+			fn(i*64 + bits.TrailingZeros64(word)) // nolint: mnd
+			word &= word - 1
+		}
+	}
+}
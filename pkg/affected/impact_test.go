@@ -0,0 +1,127 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+)
+
+func TestGraphImpact(t *testing.T) {
+	type impact struct {
+		pkg      string
+		packages int
+		groups   int
+		chain    int
+	}
+
+	testCases := map[string]struct {
+		fn        GroupFunc
+		depth     int
+		firewalls []Firewall
+		expected  []impact
+	}{
+		"RanksPackagesByDependents": {
+			expected: []impact{
+				{pkg: "foo.com/pkg/util", packages: 5, chain: 3},
+				{pkg: "foo.com/pkg/log", packages: 4, chain: 2},
+				{pkg: "foo.com/pkg/svc", packages: 2, chain: 1},
+				{pkg: "foo.com/cmd/a", packages: 1},
+				{pkg: "foo.com/cmd/b", packages: 1},
+				{pkg: "foo.com/cmd/c", packages: 1},
+			},
+		},
+		"CountsGroups": {
+			fn: GroupByPkgAfterPrefix("foo.com", 0),
+			expected: []impact{
+				{pkg: "foo.com/pkg/util", packages: 5, groups: 2, chain: 3},
+				{pkg: "foo.com/pkg/log", packages: 4, groups: 2, chain: 2},
+				{pkg: "foo.com/pkg/svc", packages: 2, groups: 2, chain: 1},
+				{pkg: "foo.com/cmd/a", packages: 1, groups: 1},
+				{pkg: "foo.com/cmd/b", packages: 1, groups: 1},
+				{pkg: "foo.com/cmd/c", packages: 1, groups: 1},
+			},
+		},
+		"LimitsDepth": {
+			depth: 1,
+			expected: []impact{
+				{pkg: "foo.com/pkg/log", packages: 3, chain: 1},
+				{pkg: "foo.com/pkg/util", packages: 3, chain: 1},
+				{pkg: "foo.com/pkg/svc", packages: 2, chain: 1},
+				{pkg: "foo.com/cmd/a", packages: 1},
+				{pkg: "foo.com/cmd/b", packages: 1},
+				{pkg: "foo.com/cmd/c", packages: 1},
+			},
+		},
+		"StopsAtFirewalls": {
+			firewalls: []Firewall{{Packages: []string{"foo.com/pkg/log"}}},
+			expected: []impact{
+				{pkg: "foo.com/pkg/util", packages: 4, chain: 2},
+				{pkg: "foo.com/pkg/svc", packages: 2, chain: 1},
+				{pkg: "foo.com/cmd/a", packages: 1},
+				{pkg: "foo.com/cmd/b", packages: 1},
+				{pkg: "foo.com/cmd/c", packages: 1},
+				{pkg: "foo.com/pkg/log", packages: 1},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			graph := testGraph(testPackages(testImports))
+
+			impacts, err := GraphImpact(graph, tc.fn, tc.depth, tc.firewalls...)
+			require.NoError(t, err)
+
+			var actual []impact
+
+			for _, i := range impacts {
+				assert.Equal(t, i.Package, i.Chain[len(i.Chain)-1])
+				assert.InDelta(t, float64(i.Packages)/6, i.Share, 0.0001)
+
+				actual = append(actual, impact{
+					pkg:      i.Package.ID,
+					packages: i.Packages,
+					groups:   i.Groups,
+					chain:    distance(i.Chain),
+				})
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestGraphImpactImportCycle(t *testing.T) {
+	graph := testGraph(testPackages(map[string][]string{
+		"foo.com/pkg/a": {"foo.com/pkg/b"},
+		"foo.com/pkg/b": {"foo.com/pkg/a"},
+	}))
+
+	_, err := GraphImpact(graph, nil, 0)
+	assert.Equal(t, module.ErrImportCycle, err)
+}
+
+func TestAnalysisImpact(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	analysis := &Analysis{
+		Graph:    graph,
+		Modified: []*module.Package{graph.Lookup("foo.com/pkg/log"), graph.Lookup("foo.com/cmd/c")},
+		MaxDepth: 1,
+	}
+
+	impacts := analysis.Impact(nil)
+
+	if assert.Len(t, impacts, 2) {
+		assert.Equal(t, "foo.com/pkg/log", impacts[0].Package.ID)
+		assert.Equal(t, 3, impacts[0].Packages)
+		assert.Equal(t, "foo.com/cmd/c", impacts[1].Package.ID)
+		assert.Equal(t, 1, impacts[1].Packages)
+	}
+}
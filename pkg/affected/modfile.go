@@ -0,0 +1,186 @@
+package affected
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// modfileDiff holds the differences of a go.mod file between two refs
+type modfileDiff struct {
+	Packages   []*packages.Package // Packages of required modules whose version changed
+	Directives []string            // Directives whose values changed, e.g go or toolchain
+	Tools      []string            // Tools from tool directives that were added or changed version
+}
+
+// diffModfile diffs a go.mod file between two refs returning a slice modified modules and a slice of
+// all packages modifiled, for example, if the module "github.com/aws/aws-sdk-go" version has
+// changed all packages within that module are considered as modified. Changes to any other
+// directives, such as the go version or toolchain, are also returned.
+func diffModfile(r vcs.FileAtRefReader, l module.PackageLoader, refA, refB, path string) (*modfileDiff, error) {
+	data, err := r.ReadFileAtRef(refA, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	modfileA, err := parseModfile(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = r.ReadFileAtRef(refB, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	modfileB, err := parseModfile(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	require := make(map[string]string)
+
+	for _, module := range modfileA.Require {
+		if module.Indirect {
+			continue
+		}
+
+		require[module.Mod.Path] = module.Mod.Version
+	}
+
+	for _, module := range modfileB.Require {
+		if module.Indirect {
+			continue
+		}
+
+		v, ok := require[module.Mod.Path]
+		if !ok { // new module
+			continue
+		}
+
+		if module.Mod.Version == v { // no change to version, remove from the map
+			delete(require, module.Mod.Path)
+		}
+	}
+
+	var modules []string
+
+	for module := range require {
+		modules = append(modules, module)
+	}
+
+	diff := &modfileDiff{
+		Directives: diffDirectives(modfileA, modfileB),
+		Tools:      diffTools(modfileA, modfileB),
+	}
+
+	if len(modules) == 0 {
+		return diff, nil
+	}
+
+	diff.Packages, err = l.Load(modules...)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// parseModfile parses a go.mod file ignoring directives unknown to the modfile package, so go.mod
+// files written by newer go versions can still be diffed
+func parseModfile(path string, data []byte) (*modfile.File, error) {
+	return modfile.ParseLax(path, data, nil)
+}
+
+// directives returns the values of every directive in a go.mod file, keyed by directive name
+func directives(f *modfile.File) map[string][]string {
+	m := make(map[string][]string)
+
+	for _, stmt := range f.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if len(x.Token) > 0 {
+				m[x.Token[0]] = append(m[x.Token[0]], strings.Join(x.Token[1:], " "))
+			}
+		case *modfile.LineBlock:
+			if len(x.Token) == 0 {
+				continue
+			}
+
+			for _, l := range x.Line {
+				m[x.Token[0]] = append(m[x.Token[0]], strings.Join(l.Token, " "))
+			}
+		}
+	}
+
+	return m
+}
+
+// diffDirectives returns the names of directives whose values differ between two go.mod files
+func diffDirectives(a, b *modfile.File) []string {
+	da, db := directives(a), directives(b)
+
+	var changed []string
+
+	for name, values := range da {
+		if strings.Join(values, "\n") != strings.Join(db[name], "\n") {
+			changed = append(changed, name)
+		}
+	}
+
+	for name := range db {
+		if _, ok := da[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+
+	return changed
+}
+
+// diffTools returns the tools from the tool directives of b that are not in a or whose version
+// differs, a tool's version is the version of the required module that provides it
+func diffTools(a, b *modfile.File) []string {
+	ta, tb := tools(a), tools(b)
+
+	var changed []string
+
+	for tool, v := range tb {
+		if va, ok := ta[tool]; !ok || va != v {
+			changed = append(changed, tool)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// tools returns the version of every tool in a go.mod file's tool directives keyed by the tool's
+// package path, tools provided by the main module have no version
+func tools(f *modfile.File) map[string]string {
+	versions := make(map[string]string, len(f.Require))
+	for _, r := range f.Require {
+		versions[r.Mod.Path] = r.Mod.Version
+	}
+
+	m := make(map[string]string)
+
+	for _, tool := range directives(f)["tool"] {
+		var provider string
+
+		for p := range versions {
+			if (tool == p || strings.HasPrefix(tool, p+"/")) && len(p) > len(provider) {
+				provider = p
+			}
+		}
+
+		m[tool] = versions[provider]
+	}
+
+	return m
+}
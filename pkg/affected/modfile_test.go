@@ -0,0 +1,142 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDirectives(t *testing.T) {
+	a, err := parseModfile("go.mod", []byte(`module foo.com
+
+go 1.21.0
+
+toolchain go1.21.4
+
+require github.com/foo/bar v1.0.0
+`))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		modfile  string
+		expected []string
+	}{
+		"ReturnsNothingWhenUnchanged": {
+			modfile: `module foo.com
+
+go 1.21.0
+
+toolchain go1.21.4
+
+require github.com/foo/bar v1.0.0 // comment
+`,
+		},
+		"ReturnsChangedDirectives": {
+			modfile: `module foo.com
+
+go 1.22.0
+
+require (
+	github.com/foo/bar v1.1.0
+)
+
+tool github.com/foo/bar/cmd/gen
+`,
+			expected: []string{"go", "toolchain", "require", "tool"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := parseModfile("go.mod", []byte(tc.modfile))
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.expected, diffDirectives(a, b))
+		})
+	}
+}
+
+func TestDiffTools(t *testing.T) {
+	a, err := parseModfile("go.mod", []byte(`module foo.com
+
+require (
+	go.uber.org/mock v0.4.0 // indirect
+	golang.org/x/tools v0.20.0 // indirect
+)
+
+tool (
+	go.uber.org/mock/mockgen
+	golang.org/x/tools/cmd/stringer
+	foo.com/cmd/gen
+)
+`))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		modfile  string
+		expected []string
+	}{
+		"ReturnsNothingWhenUnchanged": {
+			modfile: `module foo.com
+
+require go.uber.org/mock v0.4.0 // indirect
+require golang.org/x/tools v0.20.0 // indirect
+
+tool go.uber.org/mock/mockgen
+tool golang.org/x/tools/cmd/stringer
+tool foo.com/cmd/gen
+`,
+		},
+		"ReturnsToolsWithChangedVersions": {
+			modfile: `module foo.com
+
+require (
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/tools v0.20.0 // indirect
+)
+
+tool (
+	go.uber.org/mock/mockgen
+	golang.org/x/tools/cmd/stringer
+	foo.com/cmd/gen
+)
+`,
+			expected: []string{"go.uber.org/mock/mockgen"},
+		},
+		"ReturnsAddedTools": {
+			modfile: `module foo.com
+
+require (
+	go.uber.org/mock v0.4.0 // indirect
+	golang.org/x/tools v0.20.0 // indirect
+)
+
+tool (
+	go.uber.org/mock/mockgen
+	golang.org/x/tools/cmd/stringer
+	golang.org/x/tools/cmd/goimports
+	foo.com/cmd/gen
+)
+`,
+			expected: []string{"golang.org/x/tools/cmd/goimports"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := parseModfile("go.mod", []byte(tc.modfile))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, diffTools(a, b))
+		})
+	}
+}
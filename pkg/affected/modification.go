@@ -0,0 +1,102 @@
+package affected
+
+import (
+	"sort"
+
+	"github.com/vidsy/affected/pkg/vcs"
+	"golang.org/x/tools/go/packages"
+)
+
+// A modification is a change to a package between two refs
+type modification struct {
+	Kind       CauseKind                // Kind of cause reported for packages affected by the modification
+	Category   CauseCategory            // Most significant category of the changes that modified the package
+	Files      []string                 // Changed files that modified the package, sorted
+	categories map[string]CauseCategory // Category of each file
+}
+
+// modifications tracks the packages modified between two refs, in the order they were modified
+type modifications struct {
+	pkgs    []*packages.Package
+	index   map[string]*modification  // Keyed by package ID
+	changes map[string]vcs.FileChange // How each changed file changed, keyed by path
+}
+
+func newModifications() *modifications {
+	return &modifications{
+		index:   make(map[string]*modification),
+		changes: make(map[string]vcs.FileChange),
+	}
+}
+
+// add records that the file modified the packages, a package keeps the kind it was first added with
+// and the most significant category it was added with. An empty file records the package as
+// modified without a file.
+func (m *modifications) add(kind CauseKind, category CauseCategory, file string, pkgs ...*packages.Package) {
+	for _, pkg := range pkgs {
+		mod, ok := m.index[pkg.ID]
+		if !ok {
+			mod = &modification{Kind: kind, Category: category, categories: make(map[string]CauseCategory)}
+			m.index[pkg.ID] = mod
+			m.pkgs = append(m.pkgs, pkg)
+		}
+
+		if category.precedes(mod.Category) {
+			mod.Category = category
+		}
+
+		if file == "" {
+			continue
+		}
+
+		if c, ok := mod.categories[file]; !ok || category.precedes(c) {
+			mod.categories[file] = category
+		}
+
+		if !mod.has(file) {
+			mod.Files = append(mod.Files, file)
+			sort.Strings(mod.Files)
+		}
+	}
+}
+
+// has reports whether the file is one of the modification's files
+func (m *modification) has(file string) bool {
+	for _, f := range m.Files {
+		if f == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// get returns the modification to the package
+func (m *modifications) get(id string) modification {
+	if mod, ok := m.index[id]; ok {
+		return *mod
+	}
+
+	return modification{Kind: CausePackage}
+}
+
+// fileChanges returns how each of the modification's files changed, in file order. Files the VCS
+// did not report a change for only have their path and category.
+func (m *modifications) fileChanges(mod modification) []FileChange {
+	if len(mod.Files) == 0 {
+		return nil
+	}
+
+	changes := make([]FileChange, len(mod.Files))
+
+	for i, file := range mod.Files {
+		change, ok := m.changes[file]
+		if !ok {
+			change = vcs.FileChange{Path: file}
+		}
+
+		changes[i] = FileChange{FileChange: change, Category: mod.categories[file]}
+	}
+
+	return changes
+}
@@ -0,0 +1,76 @@
+package affected
+
+import (
+	"sort"
+
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// Affected packages, groups and causes are always returned in the same order for the same input, so
+// output can be diffed, cached and compared against golden files:
+//
+//   - Packages are sorted by ID
+//   - Groups are sorted by name, their packages by ID
+//   - Causes are sorted by the ID of the modified package then by the length of the import path,
+//     ties are broken by the IDs along the import path. Global causes follow, sorted by trigger.
+
+// sortPackages sorts affected packages by ID and the causes of each package
+func sortPackages(pkgs []Package) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ID < pkgs[j].ID
+	})
+
+	for _, pkg := range pkgs {
+		sortCauses(pkg.Causes)
+	}
+}
+
+// sortGroups sorts groups by name, the packages of each group and the causes of each group
+func sortGroups(groups Groups) {
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	for _, g := range groups {
+		sortPackages(g.Packages)
+		sortCauses(g.Causes)
+	}
+}
+
+// sortCauses sorts causes by the ID of the modified package then by import path, global causes
+// are sorted last by trigger
+func sortCauses(causes []Cause) {
+	sort.SliceStable(causes, func(i, j int) bool {
+		a, b := causes[i], causes[j]
+
+		if global := a.Kind == CauseGlobal; global != (b.Kind == CauseGlobal) {
+			return !global
+		}
+
+		if a.Kind == CauseGlobal {
+			return a.Trigger < b.Trigger
+		}
+
+		if a.Package.ID != b.Package.ID {
+			return a.Package.ID < b.Package.ID
+		}
+
+		return lessImportPath(a.ImportPath, b.ImportPath)
+	})
+}
+
+// lessImportPath reports whether import path a sorts before b, shorter paths sort first and paths
+// of the same length are compared by the IDs along them
+func lessImportPath(a, b module.ImportPath) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return a[i].ID < b[i].ID
+		}
+	}
+
+	return false
+}
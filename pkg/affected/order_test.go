@@ -0,0 +1,107 @@
+package affected
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestAffectedOrder(t *testing.T) {
+	var expected []byte
+
+	for seed := int64(0); seed < 10; seed++ {
+		pkgs := testPackages(testImports)
+		in := make([]*packages.Package, 0, len(pkgs))
+
+		for _, pkg := range pkgs {
+			in = append(in, pkg)
+		}
+
+		r := rand.New(rand.NewSource(seed))
+		r.Shuffle(len(in), func(i, j int) { in[i], in[j] = in[j], in[i] })
+
+		graph := module.NewGraph(in...)
+
+		modified := newModifications()
+		modified.add(CausePackage, CategoryGo, "/repo/pkg/util/b.go", &packages.Package{ID: "foo.com/pkg/util"})
+		modified.add(CausePackage, CategoryGo, "/repo/pkg/svc/svc.go", &packages.Package{ID: "foo.com/pkg/svc"})
+		modified.add(CausePackage, CategoryGo, "/repo/pkg/util/a.go", &packages.Package{ID: "foo.com/pkg/util"})
+
+		affected := affected(graph, &PackagesOptions{ImportPathLimit: 0}, modified)
+		affected = global(affected, graph.Packages(), Trigger{Name: "b"}, Trigger{Name: "a"})
+
+		var ids []string
+		for _, pkg := range affected {
+			ids = append(ids, pkg.ID)
+		}
+
+		assert.Equal(t, []string{"foo.com/cmd/a", "foo.com/cmd/b", "foo.com/cmd/c", "foo.com/pkg/log", "foo.com/pkg/svc", "foo.com/pkg/util"}, ids)
+
+		var causes []string
+		for _, cause := range affected[0].Causes {
+			causes = append(causes, cause.Trigger)
+
+			if cause.Package != nil {
+				causes[len(causes)-1] = cause.Package.ID
+			}
+		}
+
+		assert.Equal(t, []string{"foo.com/pkg/svc", "foo.com/pkg/util", "a", "b"}, causes)
+		assert.Equal(t, []string{"/repo/pkg/util/a.go", "/repo/pkg/util/b.go"}, affected[0].Causes[1].Files)
+
+		b, err := json.Marshal(GroupPackages(GroupByPkgAfterPrefix("foo.com", 0), affected...))
+		require.NoError(t, err)
+
+		if expected == nil {
+			expected = b
+		}
+
+		assert.Equal(t, string(expected), string(b))
+	}
+}
+
+func TestSortCauses(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	path := func(ids ...string) module.ImportPath {
+		p := make(module.ImportPath, len(ids))
+		for i, id := range ids {
+			p[i] = graph.Lookup(id)
+		}
+
+		return p
+	}
+
+	causes := []Cause{
+		{Kind: CauseGlobal, Trigger: "go.mod"},
+		{Package: graph.Lookup("foo.com/pkg/util"), ImportPath: path("foo.com/cmd/b", "foo.com/pkg/log", "foo.com/pkg/util")},
+		{Package: graph.Lookup("foo.com/pkg/util"), ImportPath: path("foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util")},
+		{Package: graph.Lookup("foo.com/pkg/util"), ImportPath: path("foo.com/pkg/log", "foo.com/pkg/util")},
+		{Package: graph.Lookup("foo.com/pkg/log"), ImportPath: path("foo.com/cmd/b", "foo.com/pkg/log")},
+	}
+
+	sortCauses(causes)
+
+	var actual [][]string
+	for _, cause := range causes {
+		var ids []string
+		for _, pkg := range cause.ImportPath {
+			ids = append(ids, pkg.ID)
+		}
+
+		actual = append(actual, ids)
+	}
+
+	assert.Equal(t, [][]string{
+		{"foo.com/cmd/b", "foo.com/pkg/log"},
+		{"foo.com/pkg/log", "foo.com/pkg/util"},
+		{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util"},
+		{"foo.com/cmd/b", "foo.com/pkg/log", "foo.com/pkg/util"},
+		nil,
+	}, actual)
+}
@@ -1,17 +1,14 @@
 package affected
 
 import (
+	"bytes"
 	"encoding/json"
-	"os"
-	"path/filepath"
+	"fmt"
+	"io"
 	"strings"
 
-	"github.com/vidsy/affected/pkg/glob"
 	"github.com/vidsy/affected/pkg/module"
 	"github.com/vidsy/affected/pkg/vcs"
-	"github.com/vidsy/affected/pkg/vcs/git"
-	"golang.org/x/mod/modfile"
-	"golang.org/x/tools/go/packages"
 )
 
 // Package represents a modified package
@@ -21,11 +18,71 @@ type Package struct {
 	Causes []Cause
 }
 
-// MarshalJSON marshals a package with its causes into a json structure
+// Distance returns the number of import hops to the nearest modified package, a package with its
+// own modifications is zero hops away
+func (p Package) Distance() int {
+	distance := -1
+
+	for _, cause := range p.Causes {
+		if d := cause.Distance(); distance < 0 || d < distance {
+			distance = d
+		}
+	}
+
+	return distance
+}
+
+// Classification is whether a package is affected by its own changes or only by changes to the
+// packages it imports
+type Classification string
+
+// Classifications
+const (
+	Direct     Classification = "direct"     // The package's own files, or files mapped to it, changed
+	Transitive Classification = "transitive" // The package imports changed packages or a global trigger fired
+)
+
+// Classification returns whether the package is directly modified or transitively affected, a
+// package is direct when it has a cause zero import hops away other than a global trigger
+func (p Package) Classification() Classification {
+	for _, cause := range p.Causes {
+		if cause.Kind != CauseGlobal && cause.Distance() == 0 {
+			return Direct
+		}
+	}
+
+	return Transitive
+}
+
+// Classified returns the packages with the classification, in the order given
+func Classified(c Classification, pkgs ...Package) []Package {
+	out := make([]Package, 0, len(pkgs))
+
+	for _, pkg := range pkgs {
+		if pkg.Classification() == c {
+			out = append(out, pkg)
+		}
+	}
+
+	return out
+}
+
+// MarshalJSON marshals a package with its causes into the legacy json structure, new fields are
+// only added to the versioned report in pkg/report. A global trigger affects the package as if it
+// had its own modifications so global causes name the package itself
 func (p *Package) MarshalJSON() ([]byte, error) {
 	causes := make([]map[string]interface{}, len(p.Causes))
 
 	for i, cause := range p.Causes {
+		if cause.Kind == CauseGlobal {
+			causes[i] = map[string]interface{}{
+				"package": p.Package,
+				"imports": module.ImportPath{p.Package},
+			}
+
+			continue
+		}
+
 		causes[i] = map[string]interface{}{
 			"package": cause.Package,
 			"imports": cause.ImportPath,
@@ -38,6 +95,156 @@ func (p *Package) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// marshalCauses converts causes into their json structure
+func marshalCauses(in []Cause) []map[string]interface{} {
+	causes := make([]map[string]interface{}, len(in))
+
+	for i, cause := range in {
+		if cause.Kind == CauseGlobal {
+			causes[i] = map[string]interface{}{
+				"kind":     cause.Kind,
+				"trigger":  cause.Trigger,
+				"distance": cause.Distance(),
+			}
+
+			continue
+		}
+
+		causes[i] = map[string]interface{}{
+			"kind":     cause.Kind,
+			"package":  cause.Package,
+			"imports":  cause.ImportPath,
+			"routes":   cause.Routes,
+			"distance": cause.Distance(),
+		}
+
+		if len(cause.Files) > 0 {
+			causes[i]["files"] = cause.Files
+		}
+
+		if len(cause.ImportPaths) > 0 {
+			causes[i]["paths"] = cause.ImportPaths
+		}
+
+		if cause.Stopped {
+			causes[i]["stopped"] = true
+		}
+	}
+
+	return causes
+}
+
+// List holds affected packages, sorted by ID
+type List []Package
+
+func (l List) String() string {
+	w := new(bytes.Buffer)
+
+	for _, pkg := range l {
+		writePackage(w, pkg)
+	}
+
+	return w.String()
+}
+
+// writePackage writes a human readable description of an affected package and its causes
+func writePackage(w io.Writer, pkg Package) {
+	fmt.Fprintf(w, "- Package:  %s (%s)\n", pkg.ID, pkg.Classification())
+
+	for _, cause := range pkg.Causes {
+		writeCause(w, cause)
+	}
+}
+
+// writeCause writes a human readable description of a cause
+func writeCause(w io.Writer, cause Cause) {
+	if cause.Kind == CauseGlobal {
+		fmt.Fprintln(w, " - Caused By: global trigger", cause.Trigger)
+		return
+	}
+
+	var notes []string
+
+	switch {
+	case cause.Kind != CausePackage:
+		notes = append(notes, string(cause.Kind))
+	case cause.Category == CategoryModule:
+		notes = append(notes, "module version changed")
+	case cause.Category != CategoryGo && cause.Category != "":
+		notes = append(notes, string(cause.Category))
+	}
+
+	switch d := cause.Distance(); d {
+	case 0:
+	case 1:
+		notes = append(notes, "1 hop")
+	default:
+		notes = append(notes, fmt.Sprintf("%d hops", d))
+	}
+
+	if cause.Stopped {
+		notes = append(notes, "stopped by firewall")
+	}
+
+	if cause.Routes > 1 {
+		notes = append(notes, fmt.Sprintf("%d routes", cause.Routes))
+	}
+
+	if len(notes) > 0 {
+		fmt.Fprintf(w, " - Caused By: %s (%s)\n", cause.Package.ID, strings.Join(notes, ", "))
+	} else {
+		fmt.Fprintln(w, " - Caused By:", cause.Package.ID)
+	}
+
+	if len(cause.Changes) > 0 {
+		for _, change := range cause.Changes {
+			writeFileChange(w, change)
+		}
+	} else {
+		for _, file := range cause.Files {
+			fmt.Fprintln(w, "  + File:", file)
+		}
+	}
+
+	if len(cause.ImportPaths) == 0 {
+		writeImportPath(w, cause.ImportPath)
+		return
+	}
+
+	for n, path := range cause.ImportPaths {
+		fmt.Fprintf(w, "  Route %d:\n", n+1)
+		writeImportPath(w, path)
+	}
+}
+
+// writeFileChange writes a changed file with how it changed, e.g + File: a.go (modified, +3 -1)
+func writeFileChange(w io.Writer, change FileChange) {
+	if change.Kind == "" {
+		fmt.Fprintln(w, "  + File:", change.Path)
+		return
+	}
+
+	notes := []string{string(change.Kind)}
+
+	if change.From != "" {
+		notes[0] = fmt.Sprintf("%s from %s", change.Kind, change.From)
+	}
+
+	if change.Binary {
+		notes = append(notes, "binary")
+	} else {
+		notes = append(notes, fmt.Sprintf("+%d -%d", change.Added, change.Deleted))
+	}
+
+	fmt.Fprintf(w, "  + File: %s (%s)\n", change.Path, strings.Join(notes, ", "))
+}
+
+func writeImportPath(w io.Writer, path module.ImportPath) {
+	for i, pkg := range path {
+		fmt.Fprintln(w, fmt.Sprintf("  %s %s", strings.Repeat(">", i), pkg.ID))
+	}
+}
+
 // PackagesOptions holds condifuration for loading pacakges and modified directories
 type PackagesOptions struct {
 	VCS interface {
@@ -49,6 +256,19 @@ type PackagesOptions struct {
 	PackageLoader    module.PackageLoader    // Package loader
 	IncludeGlobs     []string                // Filename globs to include
 	ExcludeGlobs     []string                // Filename globs to exclude
+	ImportPathLimit  int                     // Maximum import paths per cause, < 1 for all paths
+	MaxDepth         int                     // Maximum import hops changes propagate, < 1 for no limit
+	Firewalls        []Firewall              // Packages whose changes do not propagate to their importers
+	Strict           bool                    // Fail if any package in the module failed to load
+	Triggers         []Trigger               // Global triggers that mark every package as affected
+	NearestPackage   bool                    // Resolve non-Go files outside packages to their nearest ancestor package
+	GroupRoots       []string                // Directory globs, files beneath a match modify every package in it
+	Dependencies     []Dependency            // Non-Go files packages depend upon
+	Protos           bool                    // Relate changed protobuf files to their generated Go packages
+	ProtoRoots       []string                // Directories protobuf files are found in, defaults to the module root
+	Generate         bool                    // Track the inputs and tools of go:generate directives
+	Embeds           bool                    // Mark packages as modified when a file they embed with go:embed changes
+	ChangeDetails    bool                    // Detect how each changed file changed, when the VCS is a vcs.FileChangesDetector
 }
 
 // PackagesOption configures packages options
@@ -84,195 +304,207 @@ func WithAppendExcludeGlobs(with ...string) PackagesOption {
 	}
 }
 
-// NoParents will result in all top level packages being analysed for modifications
-func NoParents(p *module.Package) bool {
-	return len(p.Parents) == 0
+// WithTriggers sets the global triggers, replacing the default triggers
+func WithTriggers(v ...Trigger) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Triggers = v
+	}
 }
 
-// Packages returns a slice of packages affected by direct or indirect changes, use PackageOptions
-// to overide defautlt behaviour
-func Packages(name, a, b string, opts ...PackagesOption) ([]Package, error) {
-	g, err := git.New()
-	if err != nil {
-		return nil, err
+// WithAppendTriggers appends the given triggers to the triggers already on the options
+func WithAppendTriggers(with ...Trigger) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Triggers = append(o.Triggers, with...)
 	}
+}
 
-	o := &PackagesOptions{
-		VCS:              g,
-		GraphConstructor: module.DefaultGraphConstructor(),
-		PackageLoader:    module.DefaultPackageLoader(),
-		IncludeGlobs:     glob.IncludeDefault(),
-		ExcludeGlobs:     glob.ExcludeDefault(),
+// WithDependencies appends dependencies on non-Go files to the import graph
+func WithDependencies(with ...Dependency) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Dependencies = append(o.Dependencies, with...)
 	}
+}
 
-	for _, opt := range opts {
-		opt(o)
+// WithProtos enables or disables relating changed protobuf files to the Go packages generated from
+// them, it is enabled by default
+func WithProtos(enabled bool) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Protos = enabled
 	}
+}
 
-	pkgs, err := o.PackageLoader.Load(name)
-	if err != nil {
-		return nil, err
+// WithProtoRoots sets the directories protobuf files are found in, imports are resolved relative
+// to each root
+func WithProtoRoots(v ...string) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.ProtoRoots = v
 	}
+}
 
-	// NOTE: There maybe a better way of resolving an absolute go file path to a package import path
-	// But at time of writting I could not find one. Ideally we would resolve package path while
-	// looping over the files which would be more efficient but since I can't figure out how to
-	// resolve a go file path to a package import path this is the best I could come up with.
-
-	dirs := make(map[string]*packages.Package)
-
-	for _, pkg := range pkgs {
-		if len(pkg.GoFiles) > 0 {
-			dirs[filepath.Dir(pkg.GoFiles[0])] = pkg
-		}
+// WithGenerate enables or disables marking packages as modified when an input to, or the tool run
+// by, one of their go:generate directives changes, it is enabled by default
+func WithGenerate(enabled bool) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Generate = enabled
 	}
+}
 
-	files, err := o.VCS.ModifiedFiles(a, b,
-		vcs.ModifiedDirectoriesIncludeGlobs(o.IncludeGlobs...),
-		vcs.ModifiedDirectoriesExcludeGlobs(o.ExcludeGlobs...))
-	if err != nil {
-		return nil, err
+// WithEmbeds enables or disables marking packages as modified when a file they embed with
+// go:embed changes, it is enabled by default
+func WithEmbeds(enabled bool) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Embeds = enabled
 	}
+}
 
-	var modified []*packages.Package
-
-	seen := make(map[string]struct{})
-
-	for _, file := range files {
-		if _, err := os.Stat(file); err != nil && !os.IsNotExist(err) {
-			return nil, err
-		}
-
-		switch {
-		case strings.Contains(file, "go.mod"):
-			updated, err := diffModfile(o.VCS, o.PackageLoader, a, b, file)
-			if err != nil {
-				return nil, err
-			}
-
-			// Add packages to the packages used to build the import graph
-			pkgs = append(pkgs, updated...)
-
-			// Add packages to modified packages
-			modified = append(modified, updated...)
-		default:
-			dir := filepath.Dir(file)
-
-			if _, seen := seen[dir]; seen {
-				continue
-			}
+// WithChangeDetails enables or disables detecting how each changed file changed, e.g the lines
+// added and deleted, it is enabled by default. Details are only detected when the VCS implements
+// vcs.FileChangesDetector, otherwise causes only list their files.
+func WithChangeDetails(enabled bool) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.ChangeDetails = enabled
+	}
+}
 
-			pkg, ok := dirs[dir]
-			if !ok {
-				continue
-			}
+// WithNearestPackage resolves modified non-Go files in directories without a Go package to the
+// package in the closest ancestor directory, e.g services/foo/config/x.json modifies services/foo
+func WithNearestPackage() PackagesOption {
+	return func(o *PackagesOptions) {
+		o.NearestPackage = true
+	}
+}
 
-			seen[dir] = struct{}{}
-			modified = append(modified, pkg)
-		}
+// WithGroupRoots sets directory globs, such as /**/services/*, a modified file in a directory
+// without a Go package beneath a matching directory modifies every package in that directory
+func WithGroupRoots(v ...string) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.GroupRoots = v
 	}
+}
 
-	// Build the graph
-	graph := module.NewGraph(pkgs...)
+// WithStrict sets whether analysis fails with LoadErrors when any package in the module failed to
+// load, when not strict load errors are returned on the Analysis
+func WithStrict(strict bool) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Strict = strict
+	}
+}
 
-	// Return packages affected by modified packages
-	return affected(graph, modified...), nil
+// WithPackageLoader sets the loader used to load the module's packages
+func WithPackageLoader(l module.PackageLoader) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.PackageLoader = l
+	}
 }
 
-func affected(graph module.Graph, pkgs ...*packages.Package) []Package {
-	m := make(map[string]*Package)
+// WithImportPathLimit sets the maximum number of distinct import paths reported for each cause, a
+// limit < 1 reports every path up to module.MaxImportPaths
+func WithImportPathLimit(limit int) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.ImportPathLimit = limit
+	}
+}
 
-	for _, pkg := range pkgs {
-		if modified := graph.Find(module.FindPackageByID(pkg.ID)); modified != nil {
-			for pkg := range graph {
-
-				path := graph.ImportPath(pkg, modified)
-				if len(path) > 0 {
-					affected, ok := m[pkg.ID]
-					if !ok {
-						affected = &Package{
-							Package: pkg,
-						}
-
-						m[pkg.ID] = affected
-					}
-
-					affected.Causes = append(affected.Causes, Cause{
-						Package:    modified,
-						ImportPath: path,
-					})
-				}
-			}
-		}
+// WithMaxDepth stops changes propagating to packages more than the given number of import hops from a
+// modified package, a depth < 1 does not limit propagation
+func WithMaxDepth(depth int) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.MaxDepth = depth
 	}
+}
 
-	affected := make([]Package, 0, len(m))
-	for _, pkg := range m {
-		affected = append(affected, *pkg)
+// WithFirewalls stops changes propagating beyond the firewall packages
+func WithFirewalls(with ...Firewall) PackagesOption {
+	return func(o *PackagesOptions) {
+		o.Firewalls = append(o.Firewalls, with...)
 	}
+}
 
-	return affected
+// NoParents will result in all top level packages being analysed for modifications
+func NoParents(p *module.Package) bool {
+	return len(p.Parents) == 0
 }
 
-// diffModfile diffs a go.mod file between two refs returning a slice modified modules and a slice of
-// all packages modifiled, for example, if the module "github.com/aws/aws-sdk-go" version has
-// changed all packages within that module are considered as modified.
-func diffModfile(r vcs.FileAtRefReader, l module.PackageLoader, refA, refB, path string) ([]*packages.Package, error) {
-	data, err := r.ReadFileAtRef(refA, filepath.Base(path))
+// Packages returns a slice of packages affected by direct or indirect changes sorted by ID, use
+// PackageOptions to overide defautlt behaviour
+func Packages(name, a, b string, opts ...PackagesOption) ([]Package, error) {
+	analysis, err := Analyse(name, a, b, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	modfileA, err := modfile.Parse(path, data, nil)
-	if err != nil {
-		return nil, err
-	}
+	return analysis.Packages, nil
+}
 
-	data, err = r.ReadFileAtRef(refB, filepath.Base(path))
-	if err != nil {
-		return nil, err
-	}
+func affected(graph *module.Graph, o *PackagesOptions, modified *modifications) []Package {
+	targets := make([]*module.Package, 0, len(modified.pkgs))
 
-	modfileB, err := modfile.Parse(path, data, nil)
-	if err != nil {
-		return nil, err
+	for _, pkg := range modified.pkgs {
+		if modified := graph.Lookup(pkg.ID); modified != nil {
+			targets = append(targets, modified)
+		}
 	}
 
-	require := make(map[string]string)
+	m := make(map[string]*Package)
+	routes := make(map[*module.Package]*module.Routes, len(targets))
 
-	for _, module := range modfileA.Require {
-		if module.Indirect {
-			continue
-		}
+	follow := firewalled(o.Firewalls)
 
-		require[module.Mod.Path] = module.Mod.Version
-	}
+	for _, path := range graph.ImportPathsFunc(follow, targets...) {
+		pkg, target := path[0], path[len(path)-1]
 
-	for _, module := range modfileB.Require {
-		if module.Indirect {
+		// Synthetic nodes are not packages so are never affected themselves
+		if pkg.Synthetic() {
 			continue
 		}
 
-		v, ok := require[module.Mod.Path]
-		if !ok { // new module
+		// Paths are found breadth first so are the shortest path to the target, packages further
+		// away than the maximum depth are not affected by it
+		if o.MaxDepth > 0 && distance(path) > o.MaxDepth {
 			continue
 		}
 
-		if module.Mod.Version == v { // no change to version, remove from the map
-			delete(require, module.Mod.Path)
+		r, ok := routes[target]
+		if !ok {
+			r = graph.RoutesFunc(target, follow)
+			routes[target] = r
 		}
-	}
 
-	var modules []string
+		affected, ok := m[pkg.ID]
+		if !ok {
+			affected = &Package{
+				Package: pkg,
+			}
+
+			m[pkg.ID] = affected
+		}
 
-	for module := range require {
-		modules = append(modules, module)
+		mod := modified.get(target.ID)
+
+		cause := Cause{
+			Kind:       mod.Kind,
+			Package:    target,
+			ImportPath: path,
+			Routes:     r.Count(pkg),
+			Files:      mod.Files,
+			Changes:    modified.fileChanges(mod),
+			Category:   mod.Category,
+			Stopped:    stopped(o.Firewalls, pkg),
+		}
+
+		if o.ImportPathLimit != 1 && cause.Routes > 1 {
+			cause.ImportPaths = r.Paths(pkg, o.ImportPathLimit)
+		}
+
+		affected.Causes = append(affected.Causes, cause)
 	}
 
-	packages, err := l.Load(modules...)
-	if err != nil {
-		return nil, err
+	affected := make([]Package, 0, len(m))
+	for _, pkg := range m {
+		affected = append(affected, *pkg)
 	}
 
-	return packages, nil
+	sortPackages(affected)
+
+	return affected
 }
@@ -0,0 +1,369 @@
+package affected
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs"
+	"golang.org/x/tools/go/packages"
+)
+
+// testPackages builds raw packages from a map of package ID to the IDs it imports
+func testPackages(imports map[string][]string) map[string]*packages.Package {
+	pkgs := make(map[string]*packages.Package, len(imports))
+
+	for id := range imports {
+		pkgs[id] = &packages.Package{
+			ID:      id,
+			PkgPath: id,
+			Imports: make(map[string]*packages.Package),
+		}
+	}
+
+	for id, deps := range imports {
+		for _, dep := range deps {
+			pkgs[id].Imports[dep] = &packages.Package{ID: dep}
+		}
+	}
+
+	return pkgs
+}
+
+func testGraph(pkgs map[string]*packages.Package) *module.Graph {
+	in := make([]*packages.Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		in = append(in, pkg)
+	}
+
+	return module.NewGraph(in...)
+}
+
+var testImports = map[string][]string{
+	"foo.com/cmd/a":    {"foo.com/pkg/svc"},
+	"foo.com/cmd/b":    {"foo.com/pkg/log"},
+	"foo.com/cmd/c":    {},
+	"foo.com/pkg/svc":  {"foo.com/pkg/log", "foo.com/pkg/util"},
+	"foo.com/pkg/log":  {"foo.com/pkg/util"},
+	"foo.com/pkg/util": {},
+}
+
+func TestAffected(t *testing.T) {
+	testCases := map[string]struct {
+		modified []string
+		expected map[string][][]string
+	}{
+		"ReturnsModifiedPackageAndImporters": {
+			modified: []string{"foo.com/pkg/log"},
+			expected: map[string][][]string{
+				"foo.com/pkg/log": {{"foo.com/pkg/log"}},
+				"foo.com/pkg/svc": {{"foo.com/pkg/svc", "foo.com/pkg/log"}},
+				"foo.com/cmd/a":   {{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/log"}},
+				"foo.com/cmd/b":   {{"foo.com/cmd/b", "foo.com/pkg/log"}},
+			},
+		},
+		"ReturnsCausePerModifiedPackage": {
+			modified: []string{"foo.com/pkg/util", "foo.com/pkg/svc"},
+			expected: map[string][][]string{
+				"foo.com/pkg/util": {{"foo.com/pkg/util"}},
+				"foo.com/pkg/log":  {{"foo.com/pkg/log", "foo.com/pkg/util"}},
+				"foo.com/pkg/svc":  {{"foo.com/pkg/svc"}, {"foo.com/pkg/svc", "foo.com/pkg/util"}},
+				"foo.com/cmd/a":    {{"foo.com/cmd/a", "foo.com/pkg/svc"}, {"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util"}},
+				"foo.com/cmd/b":    {{"foo.com/cmd/b", "foo.com/pkg/log", "foo.com/pkg/util"}},
+			},
+		},
+		"IgnoresPackagesNotInGraph": {
+			modified: []string{"bar.com/pkg/x"},
+			expected: map[string][][]string{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pkgs := testPackages(testImports)
+			graph := testGraph(pkgs)
+
+			modified := newModifications()
+			for _, id := range tc.modified {
+				modified.add(CausePackage, CategoryGo, "", &packages.Package{ID: id})
+			}
+
+			actual := make(map[string][][]string)
+
+			for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified) {
+				for _, cause := range pkg.Causes {
+					path := make([]string, len(cause.ImportPath))
+					for i, p := range cause.ImportPath {
+						path[i] = p.ID
+					}
+
+					assert.Equal(t, cause.Package, cause.ImportPath[len(cause.ImportPath)-1])
+
+					actual[pkg.ID] = append(actual[pkg.ID], path)
+				}
+			}
+
+			assert.Len(t, actual, len(tc.expected))
+
+			for id, paths := range tc.expected {
+				assert.ElementsMatch(t, paths, actual[id], id)
+			}
+		})
+	}
+}
+
+func TestAffectedImportPathLimit(t *testing.T) {
+	testCases := map[string]struct {
+		limit int
+		paths int
+	}{
+		"ReportsOnlyShortestPathByDefault": {
+			limit: 1,
+			paths: 0,
+		},
+		"ReportsAllPaths": {
+			limit: 0,
+			paths: 2,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			graph := testGraph(testPackages(testImports))
+
+			modified := newModifications()
+			modified.add(CausePackage, CategoryGo, "", &packages.Package{ID: "foo.com/pkg/util"})
+
+			for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: tc.limit}, modified) {
+				if pkg.ID != "foo.com/cmd/a" {
+					continue
+				}
+
+				assert.Len(t, pkg.Causes, 1)
+				assert.Equal(t, uint64(2), pkg.Causes[0].Routes)
+				assert.Len(t, pkg.Causes[0].ImportPaths, tc.paths)
+				assert.Len(t, pkg.Causes[0].ImportPath, 3)
+			}
+		})
+	}
+}
+
+func TestAffectedMaxDepth(t *testing.T) {
+	testCases := map[string]struct {
+		depth    int
+		expected map[string]int
+	}{
+		"PropagatesToEveryImporterWithoutLimit": {
+			depth: 0,
+			expected: map[string]int{
+				"foo.com/pkg/util": 0,
+				"foo.com/pkg/log":  1,
+				"foo.com/pkg/svc":  1,
+				"foo.com/cmd/a":    2,
+				"foo.com/cmd/b":    2,
+			},
+		},
+		"StopsBeyondMaxDepth": {
+			depth: 1,
+			expected: map[string]int{
+				"foo.com/pkg/util": 0,
+				"foo.com/pkg/log":  1,
+				"foo.com/pkg/svc":  1,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			graph := testGraph(testPackages(testImports))
+
+			modified := newModifications()
+			modified.add(CausePackage, CategoryGo, "", &packages.Package{ID: "foo.com/pkg/util"})
+
+			actual := make(map[string]int)
+
+			for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: 1, MaxDepth: tc.depth}, modified) {
+				actual[pkg.ID] = pkg.Distance()
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestCauseDistance(t *testing.T) {
+	graph := module.NewGraph(
+		&packages.Package{ID: "foo.com/cmd/a", Imports: map[string]*packages.Package{"foo.com/pkg/db": {ID: "foo.com/pkg/db"}}},
+		&packages.Package{ID: "foo.com/pkg/db", Imports: map[string]*packages.Package{module.SyntheticPrefix + "migrations": {ID: module.SyntheticPrefix + "migrations"}}},
+		module.NewSyntheticPackage("migrations"),
+	)
+
+	modified := newModifications()
+	modified.add(CausePackage, CategoryDependency, "migrations/1.sql", &packages.Package{ID: module.SyntheticPrefix + "migrations"})
+
+	actual := make(map[string]int)
+
+	for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified) {
+		actual[pkg.ID] = pkg.Causes[0].Distance()
+	}
+
+	assert.Equal(t, map[string]int{"foo.com/pkg/db": 0, "foo.com/cmd/a": 1}, actual)
+}
+
+func TestCauseChanges(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	modified := newModifications()
+	modified.changes["/repo/pkg/util/util.go"] = vcs.FileChange{Path: "/repo/pkg/util/util.go", Kind: vcs.ChangeModified, Added: 3, Deleted: 1}
+	modified.changes["/repo/pkg/util/logo.png"] = vcs.FileChange{Path: "/repo/pkg/util/logo.png", Kind: vcs.ChangeAdded, Binary: true}
+	modified.add(CausePackage, CategoryEmbed, "/repo/pkg/util/logo.png", &packages.Package{ID: "foo.com/pkg/util"})
+	modified.add(CausePackage, CategoryGo, "/repo/pkg/util/util.go", &packages.Package{ID: "foo.com/pkg/util"})
+	modified.add(CausePackage, CategoryInclude, "/repo/cmd/c/README.md", &packages.Package{ID: "foo.com/cmd/c"})
+
+	var util, c Cause
+
+	for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified) {
+		switch pkg.ID {
+		case "foo.com/pkg/util":
+			util = pkg.Causes[0]
+		case "foo.com/cmd/c":
+			c = pkg.Causes[0]
+		}
+	}
+
+	// The most significant category wins regardless of the order files were added in
+	assert.Equal(t, CategoryGo, util.Category)
+	assert.Equal(t, []FileChange{
+		{FileChange: vcs.FileChange{Path: "/repo/pkg/util/logo.png", Kind: vcs.ChangeAdded, Binary: true}, Category: CategoryEmbed},
+		{FileChange: vcs.FileChange{Path: "/repo/pkg/util/util.go", Kind: vcs.ChangeModified, Added: 3, Deleted: 1}, Category: CategoryGo},
+	}, util.Changes)
+
+	assert.Equal(t, CategoryInclude, c.Category)
+	assert.Equal(t, []FileChange{
+		{FileChange: vcs.FileChange{Path: "/repo/cmd/c/README.md"}, Category: CategoryInclude},
+	}, c.Changes)
+
+	w := new(bytes.Buffer)
+	writeCause(w, util)
+	writeCause(w, c)
+
+	assert.Equal(t, ` - Caused By: foo.com/pkg/util
+  + File: /repo/pkg/util/logo.png (added, binary)
+  + File: /repo/pkg/util/util.go (modified, +3 -1)
+   foo.com/pkg/util
+ - Caused By: foo.com/cmd/c (include)
+  + File: /repo/cmd/c/README.md
+   foo.com/cmd/c
+`, w.String())
+}
+
+func TestPackageMarshalJSON(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	modified := newModifications()
+	modified.changes["/repo/pkg/util/util.go"] = vcs.FileChange{Path: "/repo/pkg/util/util.go", Kind: vcs.ChangeModified, Added: 3, Deleted: 1}
+	modified.add(CausePackage, CategoryGo, "/repo/pkg/util/util.go", &packages.Package{ID: "foo.com/pkg/util"})
+
+	pkgs := affected(graph, &PackagesOptions{ImportPathLimit: 2}, modified)
+	pkgs = append(pkgs, Package{
+		Package: graph.Lookup("foo.com/cmd/c"),
+		Causes:  []Cause{{Kind: CauseGlobal, Category: CategoryTrigger, Trigger: "go-version"}},
+	})
+
+	sortPackages(pkgs)
+
+	actual, err := json.Marshal(pkgs)
+	require.NoError(t, err)
+
+	// The legacy json structure is the baseline's, new cause details are only in the versioned report
+	expected, err := os.ReadFile("testdata/legacy.json")
+	require.NoError(t, err)
+
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestPackageClassification(t *testing.T) {
+	graph := module.NewGraph(
+		&packages.Package{ID: "foo.com/cmd/a", Imports: map[string]*packages.Package{"foo.com/pkg/db": {ID: "foo.com/pkg/db"}}},
+		&packages.Package{ID: "foo.com/pkg/db", Imports: map[string]*packages.Package{module.SyntheticPrefix + "migrations": {ID: module.SyntheticPrefix + "migrations"}}},
+		module.NewSyntheticPackage("migrations"),
+	)
+
+	a, db, migrations := graph.Lookup("foo.com/cmd/a"), graph.Lookup("foo.com/pkg/db"), graph.Lookup(module.SyntheticPrefix+"migrations")
+
+	testCases := map[string]struct {
+		pkg      Package
+		expected Classification
+	}{
+		"OwnChanges": {
+			pkg:      Package{Package: db, Causes: []Cause{{Kind: CausePackage, Package: db, ImportPath: module.ImportPath{db}}}},
+			expected: Direct,
+		},
+		"MappedFiles": {
+			pkg:      Package{Package: db, Causes: []Cause{{Kind: CausePackage, Package: migrations, ImportPath: module.ImportPath{db, migrations}}}},
+			expected: Direct,
+		},
+		"ImportedChanges": {
+			pkg:      Package{Package: a, Causes: []Cause{{Kind: CausePackage, Package: db, ImportPath: module.ImportPath{a, db}}}},
+			expected: Transitive,
+		},
+		"OwnAndImportedChanges": {
+			pkg: Package{Package: a, Causes: []Cause{
+				{Kind: CausePackage, Package: db, ImportPath: module.ImportPath{a, db}},
+				{Kind: CausePackage, Package: a, ImportPath: module.ImportPath{a}},
+			}},
+			expected: Direct,
+		},
+		"GlobalTrigger": {
+			pkg:      Package{Package: a, Causes: []Cause{{Kind: CauseGlobal, Trigger: "go.mod"}}},
+			expected: Transitive,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.pkg.Classification())
+		})
+	}
+}
+
+func TestClassified(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	modified := newModifications()
+	modified.add(CausePackage, CategoryGo, "", &packages.Package{ID: "foo.com/pkg/log"}, &packages.Package{ID: "foo.com/cmd/c"})
+
+	pkgs := affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified)
+
+	ids := func(pkgs []Package) []string {
+		out := make([]string, len(pkgs))
+		for i, pkg := range pkgs {
+			out[i] = pkg.ID
+		}
+
+		return out
+	}
+
+	assert.Equal(t, []string{"foo.com/cmd/c", "foo.com/pkg/log"}, ids(Classified(Direct, pkgs...)))
+	assert.Equal(t, []string{"foo.com/cmd/a", "foo.com/cmd/b", "foo.com/pkg/svc"}, ids(Classified(Transitive, pkgs...)))
+}
@@ -0,0 +1,126 @@
+package affected
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/proto"
+	"golang.org/x/tools/go/packages"
+)
+
+// protos adds a synthetic node for each protobuf file beneath the roots to the packages. The Go
+// package generated from a file imports the file's node, and each node imports the nodes of the
+// files it imports, so a change to a file affects its generated package and the importers of that
+// package before the generated code is regenerated. Files are only parsed when a protobuf file
+// has changed.
+func protos(pkgs []*packages.Package, changed []string, modified *modifications, roots ...string) ([]*packages.Package, error) {
+	var changes []string
+
+	for _, file := range changed {
+		if filepath.Ext(file) == ".proto" {
+			changes = append(changes, file)
+		}
+	}
+
+	if len(changes) == 0 || len(roots) == 0 {
+		return pkgs, nil
+	}
+
+	// Changed files are absolute with symlinks resolved, roots must be too for walked files to match
+	roots, err := resolveRoots(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*proto.File
+
+	seen := make(map[string]struct{})
+
+	for _, root := range roots {
+		walked, err := proto.Walk(root)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range walked {
+			if _, ok := seen[f.Path]; !ok {
+				seen[f.Path] = struct{}{}
+				files = append(files, f)
+			}
+		}
+	}
+
+	index := proto.NewIndex(files, roots...)
+
+	ids := make(map[string]struct{}, len(pkgs))
+	for _, pkg := range pkgs {
+		ids[pkg.ID] = struct{}{}
+	}
+
+	nodes := make(map[string]*packages.Package, len(files))
+
+	for _, f := range files {
+		nodes[f.Path] = module.NewSyntheticPackage(protoName(f.Path, roots...))
+	}
+
+	edges := make(map[string][]*packages.Package)
+
+	for _, f := range files {
+		node := nodes[f.Path]
+
+		for _, imp := range f.Imports {
+			if dep := index.Resolve(imp); dep != nil {
+				node.Imports[imp] = nodes[dep.Path]
+			}
+		}
+
+		// The generated package may not exist yet, or belong to another module
+		if _, ok := ids[f.GoPackage]; ok {
+			edges[f.GoPackage] = append(edges[f.GoPackage], node)
+		}
+	}
+
+	for _, file := range changes {
+		if node, ok := nodes[file]; ok {
+			modified.add(CauseProto, CategoryProto, file, node)
+		}
+	}
+
+	out := module.Depend(pkgs, edges)
+
+	for _, f := range files {
+		out = append(out, nodes[f.Path])
+	}
+
+	return out, nil
+}
+
+// resolveRoots makes each root absolute and resolves any symlinks in it
+func resolveRoots(roots []string) ([]string, error) {
+	out := make([]string, len(roots))
+
+	for i, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+
+		if out[i], err = filepath.EvalSymlinks(abs); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// protoName names a protobuf file's node by its path relative to the first root containing it
+func protoName(path string, roots ...string) string {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	return path
+}
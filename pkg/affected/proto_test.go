@@ -0,0 +1,114 @@
+package affected
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestProtos(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-proto")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	write := func(name, src string) string {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+
+		return path
+	}
+
+	common := write("proto/common/id.proto", `option go_package = "foo.com/pb/common";`)
+	write("proto/user/user.proto", `import "common/id.proto";
+option go_package = "foo.com/pb/user;userpb";`)
+
+	raw := testPackages(map[string][]string{
+		"foo.com/cmd/a":     {"foo.com/pb/user"},
+		"foo.com/pb/user":   {},
+		"foo.com/pkg/other": {},
+	})
+
+	in := make([]*packages.Package, 0, len(raw))
+	for _, pkg := range raw {
+		in = append(in, pkg)
+	}
+
+	modified := newModifications()
+
+	pkgs, err := protos(in, []string{common}, modified, filepath.Join(root, "proto"))
+	require.NoError(t, err)
+
+	graph := module.NewGraph(pkgs...)
+
+	paths := make(map[string][]string)
+
+	for _, pkg := range affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified) {
+		require.Len(t, pkg.Causes, 1)
+		assert.Equal(t, CauseProto, pkg.Causes[0].Kind)
+		assert.Equal(t, []string{common}, pkg.Causes[0].Files)
+
+		for _, p := range pkg.Causes[0].ImportPath {
+			paths[pkg.ID] = append(paths[pkg.ID], p.ID)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"foo.com/pb/user": {"foo.com/pb/user", "file:user/user.proto", "file:common/id.proto"},
+		"foo.com/cmd/a":   {"foo.com/cmd/a", "foo.com/pb/user", "file:user/user.proto", "file:common/id.proto"},
+	}, paths)
+}
+
+func TestProtosUnchanged(t *testing.T) {
+	in := []*packages.Package{{ID: "foo.com/cmd/a"}}
+
+	pkgs, err := protos(in, []string{"/repo/cmd/a/main.go"}, newModifications(), "/does/not/exist")
+	require.NoError(t, err)
+	assert.Equal(t, in, pkgs)
+}
+
+func TestProtosRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "affected-proto")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	// Changed files are symlink resolved, the temporary directory may not be
+	root, err := filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	file := filepath.Join(root, "proto", "user", "user.proto")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, ioutil.WriteFile(file, []byte(`option go_package = "foo.com/pb/user";`), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "proto"), filepath.Join(root, "link")))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(root))
+
+	defer os.Chdir(wd) // nolint: errcheck
+
+	testCases := map[string]string{
+		"Absolute": filepath.Join(root, "proto"),
+		"Relative": "proto",
+		"Symlink":  filepath.Join(root, "link"),
+	}
+
+	for name, r := range testCases {
+		modified := newModifications()
+
+		_, err := protos([]*packages.Package{{ID: "foo.com/pb/user"}}, []string{file}, modified, r)
+		require.NoError(t, err, name)
+
+		require.Len(t, modified.pkgs, 1, name)
+		assert.Equal(t, module.SyntheticPrefix+"user/user.proto", modified.pkgs[0].ID, name)
+	}
+}
@@ -0,0 +1,85 @@
+package affected
+
+import (
+	"github.com/vidsy/affected/pkg/glob"
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// A Trigger is a change that affects every package in the module, such as a change to the version
+// of Go the module is built with. A trigger fires when a file matching one of its globs changes or
+// when the value of its go.mod directive changes.
+type Trigger struct {
+	Name      string   // Name of the trigger, reported as the cause
+	Globs     []string // File name globs that fire the trigger when a matching file changes
+	Directive string   // go.mod directive that fires the trigger when its value changes
+}
+
+// DefaultTriggers returns the built-in global triggers, these fire on changes to the go and
+// toolchain directives in go.mod and to checked in go.env and .go-version files
+func DefaultTriggers() []Trigger {
+	return []Trigger{
+		{Name: "go.mod go directive", Directive: "go"},
+		{Name: "go.mod toolchain directive", Directive: "toolchain"},
+		{Name: "go.env", Globs: []string{"**/go.env", "/**/go.env"}},
+		{Name: ".go-version", Globs: []string{"**/.go-version", "/**/.go-version"}},
+	}
+}
+
+// fired returns the triggers that fire for the changed files and go.mod directives
+func fired(triggers []Trigger, files, directives []string) []Trigger {
+	var out []Trigger
+
+	for _, t := range triggers {
+		if t.fires(files, directives) {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func (t Trigger) fires(files, directives []string) bool {
+	if t.Directive != "" {
+		for _, d := range directives {
+			if d == t.Directive {
+				return true
+			}
+		}
+	}
+
+	return len(t.Globs) > 0 && len(glob.Include(files, t.Globs...)) > 0
+}
+
+// global marks every package in the module as affected by each of the fired triggers, packages
+// already affected keep their existing causes
+func global(affected []Package, pkgs []*module.Package, triggers ...Trigger) []Package {
+	if len(triggers) == 0 {
+		return affected
+	}
+
+	index := make(map[string]int, len(affected))
+	for i, pkg := range affected {
+		index[pkg.ID] = i
+	}
+
+	for _, pkg := range pkgs {
+		i, ok := index[pkg.ID]
+		if !ok {
+			i = len(affected)
+			index[pkg.ID] = i
+			affected = append(affected, Package{Package: pkg})
+		}
+
+		for _, t := range triggers {
+			affected[i].Causes = append(affected[i].Causes, Cause{
+				Kind:     CauseGlobal,
+				Category: CategoryTrigger,
+				Trigger:  t.Name,
+			})
+		}
+	}
+
+	sortPackages(affected)
+
+	return affected
+}
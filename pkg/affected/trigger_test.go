@@ -0,0 +1,69 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFired(t *testing.T) {
+	testCases := map[string]struct {
+		files      []string
+		directives []string
+		expected   []string
+	}{
+		"FiresOnDirectiveChange": {
+			directives: []string{"require", "toolchain"},
+			expected:   []string{"go.mod toolchain directive"},
+		},
+		"FiresOnFileChange": {
+			files:    []string{"/repo/services/a/main.go", "/repo/go.env"},
+			expected: []string{"go.env"},
+		},
+		"DoesNotFireOnOtherChanges": {
+			files:      []string{"/repo/services/a/main.go", "/repo/go.mod"},
+			directives: []string{"require"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var names []string
+			for _, trigger := range fired(DefaultTriggers(), tc.files, tc.directives) {
+				names = append(names, trigger.Name)
+			}
+
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestGlobal(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	affected := []Package{
+		{
+			Package: graph.Lookup("foo.com/pkg/util"),
+			Causes:  []Cause{{Kind: CausePackage, Package: graph.Lookup("foo.com/pkg/util")}},
+		},
+	}
+
+	pkgs := global(affected, graph.Packages(), Trigger{Name: "go.env"})
+	require.Len(t, pkgs, len(testImports))
+
+	for _, pkg := range pkgs {
+		last := pkg.Causes[len(pkg.Causes)-1]
+
+		assert.Equal(t, CauseGlobal, last.Kind)
+		assert.Equal(t, "go.env", last.Trigger)
+
+		if pkg.ID == "foo.com/pkg/util" {
+			assert.Len(t, pkg.Causes, 2)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package affected
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// An Explanation explains why a package is, or is not, affected
+type Explanation struct {
+	Package  *module.Package // The explained package
+	Affected bool            // The package is affected
+	Modified bool            // The package itself has modifications
+	Causes   []Cause         // Why the package is affected
+	Reason   string          // Why the package is not affected
+}
+
+// Why explains why the package is, or is not, affected by the analysed changes
+func (a *Analysis) Why(pkg *module.Package) *Explanation {
+	e := &Explanation{Package: pkg}
+
+	for _, m := range a.Modified {
+		if m == pkg {
+			e.Modified = true
+			break
+		}
+	}
+
+	for _, p := range a.Packages {
+		if p.Package == pkg {
+			e.Affected = true
+			e.Causes = p.Causes
+
+			return e
+		}
+	}
+
+	reachable, nearest := a.nearest(pkg, nil), a.nearest(pkg, firewalled(a.Firewalls))
+
+	switch {
+	case len(a.Modified) == 0 && len(a.Files) == 0:
+		e.Reason = "no packages were modified"
+	case reachable != nil && nearest == nil:
+		e.Reason = fmt.Sprintf("changes to %s are stopped by the firewall package %s", reachable[len(reachable)-1].ID, boundary(a.Firewalls, reachable).ID)
+	case nearest != nil && a.MaxDepth > 0:
+		e.Reason = fmt.Sprintf("the nearest modified package is %d import hops away, beyond the maximum depth of %d", distance(nearest), a.MaxDepth)
+	default:
+		e.Reason = fmt.Sprintf("no changed dependency reachable, %d modified packages but none are imported by %s", len(a.Modified), pkg.ID)
+	}
+
+	return e
+}
+
+// nearest returns the import path from the package to the nearest modified package or file node it
+// imports, only following the imports fn returns true for. If it imports none the return value will
+// be nil
+func (a *Analysis) nearest(pkg *module.Package, fn module.EdgeFunc) module.ImportPath {
+	var nearest module.ImportPath
+
+	targets := append(append([]*module.Package{}, a.Modified...), a.Files...)
+
+	for _, path := range a.Graph.ImportPathsFunc(fn, targets...) {
+		if path[0] == pkg && (nearest == nil || distance(path) < distance(nearest)) {
+			nearest = path
+		}
+	}
+
+	return nearest
+}
+
+// String returns a human readable explanation
+func (e *Explanation) String() string {
+	w := new(bytes.Buffer)
+
+	if !e.Affected {
+		fmt.Fprintf(w, "%s is not affected: %s\n", e.Package.ID, e.Reason)
+		return w.String()
+	}
+
+	fmt.Fprintf(w, "%s is affected", e.Package.ID)
+
+	if e.Modified {
+		fmt.Fprint(w, ", it has modifications")
+	}
+
+	fmt.Fprintf(w, " (%d causes)\n", len(e.Causes))
+
+	for _, cause := range e.Causes {
+		writeCause(w, cause)
+	}
+
+	return w.String()
+}
+
+// MarshalJSON marshals the explanation into a json structure
+func (e *Explanation) MarshalJSON() ([]byte, error) {
+	v := map[string]interface{}{
+		"package":  e.Package.ID,
+		"affected": e.Affected,
+		"modified": e.Modified,
+		"causes":   marshalCauses(e.Causes),
+	}
+
+	if e.Reason != "" {
+		v["reason"] = e.Reason
+	}
+
+	return json.Marshal(v)
+}
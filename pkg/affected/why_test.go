@@ -0,0 +1,116 @@
+package affected
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestWhy(t *testing.T) {
+	graph := testGraph(testPackages(testImports))
+
+	modified := newModifications()
+	modified.add(CausePackage, CategoryGo, "/repo/pkg/log/log.go", &packages.Package{ID: "foo.com/pkg/log"})
+	modified.add(CausePackage, CategoryModule, "/repo/go.mod", &packages.Package{ID: "foo.com/pkg/util"})
+
+	analysis := &Analysis{
+		Graph:    graph,
+		Modified: []*module.Package{graph.Lookup("foo.com/pkg/log"), graph.Lookup("foo.com/pkg/util")},
+		Packages: affected(graph, &PackagesOptions{ImportPathLimit: 1}, modified),
+	}
+
+	testCases := map[string]struct {
+		analysis *Analysis
+		pkg      string
+		affected bool
+		modified bool
+		text     string
+	}{
+		"Affected": {
+			analysis: analysis,
+			pkg:      "foo.com/cmd/b",
+			affected: true,
+			text: `foo.com/cmd/b is affected (2 causes)
+ - Caused By: foo.com/pkg/log (1 hop)
+  + File: /repo/pkg/log/log.go
+   foo.com/cmd/b
+  > foo.com/pkg/log
+ - Caused By: foo.com/pkg/util (module version changed, 2 hops)
+  + File: /repo/go.mod
+   foo.com/cmd/b
+  > foo.com/pkg/log
+  >> foo.com/pkg/util
+`,
+		},
+		"Modified": {
+			analysis: analysis,
+			pkg:      "foo.com/pkg/log",
+			affected: true,
+			modified: true,
+			text: `foo.com/pkg/log is affected, it has modifications (2 causes)
+ - Caused By: foo.com/pkg/log
+  + File: /repo/pkg/log/log.go
+   foo.com/pkg/log
+ - Caused By: foo.com/pkg/util (module version changed, 1 hop)
+  + File: /repo/go.mod
+   foo.com/pkg/log
+  > foo.com/pkg/util
+`,
+		},
+		"NotReachable": {
+			analysis: analysis,
+			pkg:      "foo.com/cmd/c",
+			text:     "foo.com/cmd/c is not affected: no changed dependency reachable, 2 modified packages but none are imported by foo.com/cmd/c\n",
+		},
+		"BeyondMaxDepth": {
+			analysis: &Analysis{
+				Graph:    graph,
+				Modified: []*module.Package{graph.Lookup("foo.com/pkg/util")},
+				MaxDepth: 1,
+			},
+			pkg:  "foo.com/cmd/a",
+			text: "foo.com/cmd/a is not affected: the nearest modified package is 2 import hops away, beyond the maximum depth of 1\n",
+		},
+		"StoppedByFirewall": {
+			analysis: &Analysis{
+				Graph:     graph,
+				Modified:  []*module.Package{graph.Lookup("foo.com/pkg/util")},
+				Firewalls: []Firewall{{Packages: []string{"foo.com/pkg/log"}}},
+			},
+			pkg:  "foo.com/cmd/b",
+			text: "foo.com/cmd/b is not affected: changes to foo.com/pkg/util are stopped by the firewall package foo.com/pkg/log\n",
+		},
+		"NothingModified": {
+			analysis: &Analysis{Graph: graph},
+			pkg:      "foo.com/cmd/c",
+			text:     "foo.com/cmd/c is not affected: no packages were modified\n",
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			e := tc.analysis.Why(graph.Lookup(tc.pkg))
+
+			assert.Equal(t, tc.affected, e.Affected)
+			assert.Equal(t, tc.modified, e.Modified)
+			assert.Equal(t, tc.text, e.String())
+
+			b, err := json.Marshal(e)
+			require.NoError(t, err)
+
+			var v map[string]interface{}
+			require.NoError(t, json.Unmarshal(b, &v))
+
+			assert.Equal(t, tc.pkg, v["package"])
+			assert.Equal(t, tc.affected, v["affected"])
+		})
+	}
+}
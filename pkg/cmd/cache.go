@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// CacheCmd returns the cache sub command which manages the on-disk package cache
+func CacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the on-disk package cache",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "clean",
+		Short:   "Remove all cached package data",
+		Example: "affected cache clean",
+		Args:    cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			dir, err := module.CacheDir()
+			if err != nil {
+				return err
+			}
+
+			return os.RemoveAll(dir)
+		},
+	})
+
+	return cmd
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
@@ -8,11 +9,40 @@ import (
 // ErrExitCode is the exit code used for erorrs writtem tp stderr
 const ErrExitCode = 1
 
+// BudgetExitCode is the exit code used when the affected packages or groups exceed a budget
+const BudgetExitCode = 3
+
+// ExecFailedExitCode is the exit code used when a command run by exec fails, whatever code the
+// command exited with, so it can't be mistaken for an exceeded budget
+const ExecFailedExitCode = 4
+
+// ExitError exits the application with a specific exit code, the error message is only written to
+// stderr if there is one
+type ExitError struct {
+	Code int
+	Msg  string
+}
+
+func (e *ExitError) Error() string {
+	return e.Msg
+}
+
 // CheckErrExit checks if the error is not nil, if not the error is written to stderr and the exits
-// the application with an error exit code
+// the application with an error exit code, or the code of an ExitError
 func CheckErrExit(err error) {
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(ErrExitCode)
+	if err == nil {
+		return
 	}
+
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		if exit.Msg != "" {
+			fmt.Fprintln(os.Stderr, exit.Msg)
+		}
+
+		os.Exit(exit.Code)
+	}
+
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(ErrExitCode)
 }
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/runner"
+)
+
+// ExecCmd returns the exec sub command which runs a command for each affected package or group
+func ExecCmd(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec [flags] -- <command> [args...]",
+		Short: "Run a command for each affected package or group in parallel",
+		Long: `Run a command for each affected package, or group of packages, in parallel.
+
+Each argument is a Go template executed with the target: {{.Package}} is the package import path
+or group name, {{.Dir}} is the package directory and {{.Packages}} the packages in the target. An
+argument of exactly {{.Packages}} expands into one argument per package.
+
+Output is streamed prefixed with the target name. A summary is printed once every command has
+finished and affected exits with code 4 if any command failed, or 3 if the affected packages exceed
+a budget before any command is run.`,
+		Example: "affected exec -j 4 -a origin/master -b HEAD -- go test {{.Package}}",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			// Failed commands are reported in the summary and through the exit code, not as usage errors
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+
+			return Exec(opts, args)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.ExecPer, "per", "package", "Run the command per package or per group, packages in no group are run on their own")
+	cmd.Flags().IntVarP(&opts.ExecJobs, "jobs", "j", 0, "Maximum number of commands to run at once, defaults to the number of CPUs")
+	cmd.Flags().StringVar(&opts.GroupByPkgPrefix, "pkg-prefix", "", "Group by package prefix")
+	cmd.Flags().IntVar(&opts.GroupByAfter, "after", 0, "Group after n (one-based numbering)")
+
+	return cmd
+}
+
+// Exec runs the command for each affected package or group
+func Exec(opts *Options, args []string) error {
+	if opts.ExecPer != "package" && opts.ExecPer != "group" {
+		return errors.New("unsupported --per, must be package or group")
+	}
+
+	fn := GroupFunc(opts)
+	if opts.ExecPer == "group" && fn == nil {
+		return errors.New("--per group requires --pkg-prefix")
+	}
+
+	analysis, err := Analyse(opts)
+	if err != nil {
+		return err
+	}
+
+	// Don't run anything for changes that exceed the budget
+	if err := CheckBudget(opts, analysis); err != nil {
+		return err
+	}
+
+	targets, err := Targets(opts, analysis)
+	if err != nil {
+		return err
+	}
+
+	r := &runner.Runner{
+		Jobs:   opts.ExecJobs,
+		Stdout: Writer(opts),
+		Stderr: os.Stderr,
+	}
+
+	results, err := r.Run(targets, args)
+	if err != nil {
+		return err
+	}
+
+	runner.WriteSummary(os.Stderr, results)
+
+	if runner.Failed(results) {
+		return &ExitError{Code: ExecFailedExitCode}
+	}
+
+	return nil
+}
+
+// Targets returns the targets to run a command for in the selected shard, sorted by name
+func Targets(opts *Options, analysis *affected.Analysis) ([]runner.Target, error) {
+	var targets []runner.Target
+
+	all, err := ClassifiedPackages(opts, analysis)
+	if err != nil {
+		return nil, err
+	}
+
+	if fn := GroupFunc(opts); fn != nil && opts.ExecPer == "group" {
+		groups := affected.GroupPackages(fn, all...)
+
+		// Packages outside every group still need the command run, so each is a target of its own
+		ungrouped := make(map[string]string)
+
+		for _, pkg := range all {
+			pkg := pkg
+
+			if _, ok := fn(&pkg); !ok {
+				ungrouped[pkg.ID] = pkg.Dir
+				groups = append(groups, affected.Group{Name: pkg.ID, Packages: []affected.Package{pkg}, Causes: pkg.Causes})
+			}
+		}
+
+		groups, err := ShardGroups(opts, analysis.Graph, groups)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range groups {
+			t := runner.Target{Name: g.Name}
+
+			for _, pkg := range g.Packages {
+				t.Packages = append(t.Packages, pkg.ID)
+			}
+
+			if dir, ok := ungrouped[g.Name]; ok {
+				t.Dir = dir
+			}
+
+			sort.Strings(t.Packages)
+			targets = append(targets, t)
+		}
+	} else {
+		pkgs, err := ShardPackages(opts, analysis.Graph, all)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pkg := range pkgs {
+			targets = append(targets, runner.Target{
+				Name:     pkg.ID,
+				Packages: runner.Packages{pkg.ID},
+				Dir:      pkg.Dir,
+			})
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Name < targets[j].Name
+	})
+
+	return targets, nil
+}
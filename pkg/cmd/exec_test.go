@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/runner"
+	"golang.org/x/tools/go/packages"
+)
+
+// testAnalysis returns the analysis of a change to foo.com/pkg/log, which is directly affected,
+// affecting foo.com/cmd/a, which imports it
+func testAnalysis() *affected.Analysis {
+	graph := module.NewGraph(
+		&packages.Package{ID: "foo.com/cmd/a", Imports: map[string]*packages.Package{"foo.com/pkg/log": {ID: "foo.com/pkg/log"}}},
+		&packages.Package{ID: "foo.com/pkg/log"},
+	)
+
+	a, log := graph.Lookup("foo.com/cmd/a"), graph.Lookup("foo.com/pkg/log")
+
+	return &affected.Analysis{
+		Graph:    graph,
+		Modified: []*module.Package{log},
+		Packages: []affected.Package{
+			{Package: a, Causes: []affected.Cause{{Kind: affected.CausePackage, Package: log, ImportPath: module.ImportPath{a, log}, Routes: 1}}},
+			{Package: log, Causes: []affected.Cause{{Kind: affected.CausePackage, Package: log, ImportPath: module.ImportPath{log}, Routes: 1}}},
+		},
+	}
+}
+
+func TestTargets(t *testing.T) {
+	analysis := testAnalysis()
+
+	testCases := map[string]struct {
+		opts     Options
+		expected []runner.Target
+	}{
+		"PerPackage": {
+			opts: Options{ExecPer: "package"},
+			expected: []runner.Target{
+				{Name: "foo.com/cmd/a", Packages: runner.Packages{"foo.com/cmd/a"}},
+				{Name: "foo.com/pkg/log", Packages: runner.Packages{"foo.com/pkg/log"}},
+			},
+		},
+		"PerGroupRunsUngroupedPackages": {
+			opts: Options{ExecPer: "group", GroupByPkgPrefix: "foo.com/cmd"},
+			expected: []runner.Target{
+				{Name: "foo.com/cmd", Packages: runner.Packages{"foo.com/cmd/a"}},
+				{Name: "foo.com/pkg/log", Packages: runner.Packages{"foo.com/pkg/log"}},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			targets, err := Targets(&tc.opts, analysis)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, targets)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/export"
+)
+
+// GraphCmd returns the graph sub command which exports the import graph
+func GraphCmd(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "graph",
+		Short:   "Export the import graph highlighting modified and affected packages",
+		Long:    "Export the import graph, or only the affected subgraph, in the dot, mermaid or json formats.",
+		Example: "affected graph --affected-only -f dot -a origin/master -b HEAD | dot -Tsvg > affected.svg",
+		RunE: func(*cobra.Command, []string) error {
+			return Graph(opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.GraphAffectedOnly, "affected-only", false, "Only export affected packages and the imports that caused them")
+	cmd.Flags().StringVar(&opts.GroupByPkgPrefix, "pkg-prefix", "", "Collapse packages into groups by package prefix")
+	cmd.Flags().IntVar(&opts.GroupByAfter, "after", 0, "Group after n (one-based numbering)")
+
+	return cmd
+}
+
+// Graph exports the import graph with the given options
+func Graph(opts *Options) error {
+	analysis, err := Analyse(opts)
+	if err != nil {
+		return err
+	}
+
+	var eopts []export.Option
+
+	if opts.GraphAffectedOnly {
+		eopts = append(eopts, export.WithAffectedOnly())
+	}
+
+	if fn := GroupFunc(opts); fn != nil {
+		eopts = append(eopts, export.WithGroups(fn))
+	}
+
+	g := export.New(analysis, eopts...)
+
+	w := Writer(opts)
+	switch opts.Format {
+	case "dot":
+		return export.DOT(w, g)
+	case "mermaid":
+		return export.Mermaid(w, g)
+	case "json":
+		return WriteJSON(w, g, true)
+	case "json-minified":
+		return WriteJSON(w, g, false)
+	default:
+		return errors.New("unsupported format")
+	}
+}
@@ -26,7 +26,11 @@ func GroupCmd(opts *Options) *cobra.Command {
 		Use:     "group",
 		Short:   "Group affected packages by a user defined group",
 		Example: "affected group --pkg-prefix foo.com/pkg --after 1 -f json -a origin/master -b HEAD > affected.json",
-		RunE: func(*cobra.Command, []string) error {
+		RunE: func(c *cobra.Command, _ []string) error {
+			// An exceeded budget is reported through the exit code, not as a usage error
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+
 			return Run(opts)
 		},
 	}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// ImpactCmd returns the impact sub command which ranks packages by their blast radius
+func ImpactCmd(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "impact",
+		Short: "Rank modified packages by the number of packages and groups they affect",
+		Long: `Rank modified packages by their blast radius, the number of packages and groups their changes
+affect, the share of the module affected and the longest import chain.
+
+Use --all to rank every package in the module by the number of packages that import it, without a
+diff, to spot risky hub packages.`,
+		Example: "affected impact --pkg-prefix github.com/vidsy/back-end/services --after 1 -a origin/master -b HEAD -f text",
+		RunE: func(*cobra.Command, []string) error {
+			return Impact(opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.ImpactAll, "all", false, "Rank every package in the module as if it were modified, commit A and B are ignored")
+	cmd.Flags().IntVar(&opts.ImpactTop, "top", 0, "Only report the n packages with the largest impact, 0 reports every package")
+	cmd.Flags().StringVar(&opts.GroupByPkgPrefix, "pkg-prefix", "", "Count groups by package prefix")
+	cmd.Flags().IntVar(&opts.GroupByAfter, "after", 0, "Group after n (one-based numbering)")
+
+	return cmd
+}
+
+// Impact writes the impact of the modified packages, or every package with --all
+func Impact(opts *Options) error {
+	var (
+		impacts affected.Impacts
+		err     error
+	)
+
+	if opts.ImpactAll {
+		impacts, err = GraphImpact(opts)
+	} else {
+		var analysis *affected.Analysis
+
+		analysis, err = Analyse(opts)
+		if err == nil {
+			impacts = analysis.Impact(GroupFunc(opts))
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if opts.ImpactTop > 0 && len(impacts) > opts.ImpactTop {
+		impacts = impacts[:opts.ImpactTop]
+	}
+
+	w := Writer(opts)
+	switch opts.Format {
+	case "json":
+		return WriteJSON(w, impacts, true)
+	case "json-minified":
+		return WriteJSON(w, impacts, false)
+	case "text":
+		return WriteText(w, impacts)
+	default:
+		return errors.New("unsupported format")
+	}
+}
+
+// GraphImpact loads the module's import graph, without analysing any changes, and returns the
+// impact of every package in it
+func GraphImpact(opts *Options) (affected.Impacts, error) {
+	if err := ResolveModule(opts); err != nil {
+		return nil, err
+	}
+
+	cfg, err := LoadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	loader, err := Loader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := loader.Load(opts.Module)
+	if err != nil {
+		return nil, err
+	}
+
+	return affected.GraphImpact(module.NewGraph(pkgs...), GroupFunc(opts), MaxDepth(opts), Firewalls(opts, cfg)...)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/export"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/query"
+)
+
+const queryLong = `Query the package import graph with a small query language, similar to bazel query.
+
+Patterns match packages by import path, a pattern ending in /... also matches every package beneath
+it and ... matches every package. Functions:
+
+  deps(x, [depth])             x and the packages it imports
+  rdeps(universe, x, [depth])  x and the packages in universe that import it
+  somepath(a, b)               the packages on the shortest import path from a to b
+  allpaths(a, b)               the packages on every import path from a to b
+  changed()                    packages modified between commit A and B
+  affected()                   packages affected by the modifications between commit A and B
+
+Sets are combined with + (union), - (except) and ^ (intersect), which all have the same precedence
+and are left associative.`
+
+// QueryCmd returns the query sub command which queries the package import graph
+func QueryCmd(opts *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "query <expr>",
+		Short:   "Query the package import graph",
+		Long:    queryLong,
+		Example: "affected query 'rdeps(github.com/vidsy/back-end/services/..., changed())' -f text",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return Query(opts, args[0])
+		},
+	}
+}
+
+// Query evaluates the query against the package import graph and writes the matching packages
+func Query(opts *Options, expr string) error {
+	// Parse before analysing so syntax errors are reported without loading packages
+	q, err := query.Parse(expr)
+	if err != nil {
+		return err
+	}
+
+	analysis, err := Analyse(opts)
+	if err != nil {
+		return err
+	}
+
+	env := &query.Env{
+		Graph:   analysis.Graph,
+		Changed: analysis.Modified,
+	}
+
+	for _, pkg := range analysis.Packages {
+		env.Affected = append(env.Affected, pkg.Package)
+	}
+
+	pkgs, err := query.Eval(q, env)
+	if err != nil {
+		return err
+	}
+
+	w := Writer(opts)
+	switch opts.Format {
+	case "json":
+		return WriteJSON(w, pkgs, true)
+	case "json-minified":
+		return WriteJSON(w, pkgs, false)
+	case "text":
+		return writePackageIDs(w, pkgs)
+	case "dot":
+		return export.DOT(w, export.New(analysis, export.WithPackages(pkgs...)))
+	case "mermaid":
+		return export.Mermaid(w, export.New(analysis, export.WithPackages(pkgs...)))
+	default:
+		return errors.New("unsupported format")
+	}
+}
+
+func writePackageIDs(w io.Writer, pkgs []*module.Package) error {
+	for _, pkg := range pkgs {
+		if _, err := fmt.Fprintln(w, pkg.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
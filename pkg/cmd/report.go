@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/report"
+	"github.com/vidsy/affected/pkg/vcs/git"
+)
+
+// Version is the version of affected, set at build time with
+// -ldflags "-X github.com/vidsy/affected/pkg/cmd.Version=v1.0.0"
+var Version = ""
+
+// ToolVersion returns the version of affected, falling back to the module version it was built
+// from when Version is not set
+func ToolVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "devel"
+}
+
+// Report returns the versioned report of the run, with the refs resolved to commits and paths
+// relative to the repository root
+func Report(opts *Options, started time.Time, analysis *affected.Analysis, pkgs []affected.Package, groups affected.Groups) (*report.Report, error) {
+	g, err := git.New()
+	if err != nil {
+		return nil, err
+	}
+
+	r := report.New(g.RepositoryDir, analysis, pkgs, groups)
+	r.Tool = report.Tool{Name: "affected", Version: ToolVersion()}
+	r.Module = opts.Module
+	r.Options = ReportOptions(opts)
+	r.Refs = report.Refs{
+		A: report.Ref{Name: opts.CommitA},
+		B: report.Ref{Name: opts.CommitB},
+	}
+
+	if r.Refs.A.SHA, err = g.ResolveRef(opts.CommitA); err != nil {
+		return nil, err
+	}
+
+	if r.Refs.B.SHA, err = g.ResolveRef(opts.CommitB); err != nil {
+		return nil, err
+	}
+
+	// Refs with unrelated histories have no merge base
+	if base, err := g.MergeBase(opts.CommitA, opts.CommitB); err == nil {
+		r.Refs.MergeBase = base
+	}
+
+	// Timestamps differ on every run so they are only included when asked for, without them the
+	// report is byte-identical for the same input
+	if opts.Timestamps {
+		finished := time.Now().UTC()
+		started = started.UTC()
+
+		r.StartedAt, r.FinishedAt = &started, &finished
+	}
+
+	return r, nil
+}
+
+// ReportOptions returns the options that change the result of a run, keyed by flag name
+func ReportOptions(opts *Options) map[string]interface{} {
+	o := map[string]interface{}{
+		"include":              opts.IncludeGlobs,
+		"exclude":              opts.ExcludeGlobs,
+		"overrideIncludeGlobs": opts.OverrideIncludeGlobs,
+		"overrideExcludeGlobs": opts.OverrideExcludeGlobs,
+		"importPaths":          opts.ImportPaths,
+		"maxDepth":             MaxDepth(opts),
+		"firewalls":            opts.Firewalls,
+		"triggers":             opts.Triggers,
+		"nearestPackage":       opts.NearestPackage,
+		"groupRoots":           opts.GroupRoots,
+		"proto":                !opts.NoProto,
+		"protoRoots":           opts.ProtoRoots,
+		"generate":             !opts.NoGenerate,
+		"embed":                !opts.NoEmbed,
+		"loader":               opts.Loader,
+		"config":               opts.Config,
+	}
+
+	if opts.OnlyDirect {
+		o["onlyDirect"] = true
+	}
+
+	if opts.OnlyTransitive {
+		o["onlyTransitive"] = true
+	}
+
+	if opts.Shard != "" {
+		o["shard"] = opts.Shard
+	}
+
+	if opts.GroupByPkgPrefix != "" {
+		o["pkgPrefix"] = opts.GroupByPkgPrefix
+		o["after"] = opts.GroupByAfter
+	}
+
+	return o
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/config"
 )
 
 const long = `Affected detects services that have directly or indirectly been modified via
@@ -13,6 +14,7 @@ const afterOffset = 1
 // Options configures how the command is run
 type Options struct {
 	// Persistent options
+	Config               string
 	Format               string
 	Module               string
 	CommitA              string
@@ -22,10 +24,47 @@ type Options struct {
 	ExcludeGlobs         []string
 	OverrideIncludeGlobs bool
 	OverrideExcludeGlobs bool
+	ImportPaths          int
+	MaxDepth             int
+	DirectOnly           bool
+	OnlyDirect           bool
+	OnlyTransitive       bool
+	Firewalls            []string
+	MaxAffected          int
+	MaxAffectedGroups    int
+	LegacyOutput         bool
+	Timestamps           bool
+	NoCache              bool
+	Loader               string
+	Strict               bool
+	AllowErrors          bool
+	Triggers             []string
+	NearestPackage       bool
+	GroupRoots           []string
+	NoProto              bool
+	ProtoRoots           []string
+	NoGenerate           bool
+	NoEmbed              bool
+	Shard                string
+	Timings              string
+
+	// Set by sub commands whose output shows how each changed file changed
+	ChangeDetails bool
 
 	// Grouping options
 	GroupByPkgPrefix string
 	GroupByAfter     int
+
+	// Graph options
+	GraphAffectedOnly bool
+
+	// Impact options
+	ImpactAll bool
+	ImpactTop int
+
+	// Exec options
+	ExecPer  string
+	ExecJobs int
 }
 
 // RootCmd returns the root CLI command
@@ -37,12 +76,17 @@ func RootCmd() *cobra.Command {
 		Short:   "Detects packages affected changes to other packages via their improts and vcs.",
 		Long:    long,
 		Example: "affected -f json -a origin/master -b HEAD > affected.json",
-		RunE: func(*cobra.Command, []string) error {
+		RunE: func(c *cobra.Command, _ []string) error {
+			// An exceeded budget is reported through the exit code, not as a usage error
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+
 			return Run(opts)
 		},
 	}
 
-	cmd.PersistentFlags().StringVarP(&opts.Format, "format", "f", "json", "e.g text/json/json-minified")
+	cmd.PersistentFlags().StringVar(&opts.Config, "config", config.DefaultFile, "Repository configuration file, by default read from the repository root")
+	cmd.PersistentFlags().StringVarP(&opts.Format, "format", "f", "json", "e.g text/json/json-minified, graph and query also support dot/mermaid")
 	cmd.PersistentFlags().StringVarP(&opts.CommitA, "a", "a", "origin/master", "Commit A")
 	cmd.PersistentFlags().StringVarP(&opts.CommitB, "b", "b", "HEAD", "Commit B")
 	cmd.PersistentFlags().BoolVarP(&opts.Discard, "discard", "d", false, "Discard output")
@@ -50,8 +94,38 @@ func RootCmd() *cobra.Command {
 	cmd.PersistentFlags().StringArrayVarP(&opts.ExcludeGlobs, "exclude", "x", []string{}, "File name globs to exclude")
 	cmd.PersistentFlags().BoolVar(&opts.OverrideIncludeGlobs, "override-include-globs", false, "Default include globs will be omitted, only globs you provide will be used")
 	cmd.PersistentFlags().BoolVar(&opts.OverrideExcludeGlobs, "override-exclude-globs", false, "Default exclude globs will be omitted, only globs you provide will be used")
+	cmd.PersistentFlags().IntVar(&opts.ImportPaths, "import-paths", 1, "Maximum number of import paths to report per cause, shortest first, 0 reports all paths")
+	cmd.PersistentFlags().IntVar(&opts.MaxDepth, "max-depth", 0, "Maximum number of import hops changes propagate, 0 does not limit propagation")
+	cmd.PersistentFlags().BoolVar(&opts.DirectOnly, "direct-only", false, "Only report modified packages and the packages that directly import them, same as --max-depth 1")
+	cmd.PersistentFlags().BoolVar(&opts.OnlyDirect, "only-direct", false, "Only report packages whose own files changed, not packages affected through their imports")
+	cmd.PersistentFlags().BoolVar(&opts.OnlyTransitive, "only-transitive", false, "Only report packages affected through their imports, not packages whose own files changed")
+	cmd.PersistentFlags().StringArrayVar(&opts.Firewalls, "firewall", []string{}, "Package import paths whose changes do not propagate to their importers, paths ending in /... include sub packages")
+	cmd.PersistentFlags().IntVar(&opts.MaxAffected, "max-affected", 0, "Exit with a budget exceeded exit code if more packages are affected, 0 does not limit the affected packages")
+	cmd.PersistentFlags().IntVar(&opts.MaxAffectedGroups, "max-affected-groups", 0, "Exit with a budget exceeded exit code if more groups are affected, requires --pkg-prefix")
+	cmd.PersistentFlags().BoolVar(&opts.LegacyOutput, "legacy-output", false, "Write JSON as a bare array of packages, or of groups, instead of the versioned report")
+	cmd.PersistentFlags().BoolVar(&opts.Timestamps, "timestamps", false, "Include the start and finish timestamps in the JSON report, runs on the same input then write different output")
+	cmd.PersistentFlags().BoolVar(&opts.NoCache, "no-cache", false, "Load all packages without using the on-disk package cache")
+	cmd.PersistentFlags().StringVar(&opts.Loader, "loader", "golist", "Package loader, golist streams go list output, packages type-checks packages with go/packages")
+	cmd.PersistentFlags().BoolVar(&opts.Strict, "strict", false, "Fail if any package in the module failed to load")
+	cmd.PersistentFlags().BoolVar(&opts.AllowErrors, "allow-errors", false, "Report package load errors as warnings rather than failing, the default without --strict, overrides --strict")
+	cmd.PersistentFlags().StringArrayVarP(&opts.Triggers, "trigger", "t", []string{}, "File name globs that mark every package as affected when a matching file changes, matched against absolute paths so prefix with /**/")
+	cmd.PersistentFlags().BoolVar(&opts.NearestPackage, "nearest-package", false, "Resolve included non-Go files outside a Go package to the package in their nearest ancestor directory")
+	cmd.PersistentFlags().BoolVar(&opts.NoProto, "no-proto", false, "Do not relate changed .proto files to the Go packages generated from them")
+	cmd.PersistentFlags().StringArrayVar(&opts.ProtoRoots, "proto-root", []string{}, "Directories .proto files are found in and imports are resolved against, defaults to the module root")
+	cmd.PersistentFlags().BoolVar(&opts.NoGenerate, "no-generate", false, "Do not track the inputs and tools of go:generate directives")
+	cmd.PersistentFlags().BoolVar(&opts.NoEmbed, "no-embed", false, "Do not mark packages as modified when a file they embed with go:embed changes")
+	cmd.PersistentFlags().StringVar(&opts.Shard, "shard", "", "Only output the i/N shard of the affected packages or groups, e.g 1/4")
+	cmd.PersistentFlags().StringVar(&opts.Timings, "timings", DefaultTimingsFile, "Timings file used to balance shards, shards are balanced by file count without timings")
+	cmd.PersistentFlags().StringArrayVar(&opts.GroupRoots, "group-root", []string{}, "Directory globs, included files outside a Go package beneath a match modify every package in it")
 
 	cmd.AddCommand(GroupCmd(opts))
+	cmd.AddCommand(WhyCmd(opts))
+	cmd.AddCommand(QueryCmd(opts))
+	cmd.AddCommand(ImpactCmd(opts))
+	cmd.AddCommand(ExecCmd(opts))
+	cmd.AddCommand(TimingsCmd(opts))
+	cmd.AddCommand(GraphCmd(opts))
+	cmd.AddCommand(CacheCmd())
 
 	return cmd
 }
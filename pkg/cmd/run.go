@@ -2,24 +2,206 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/config"
 	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs/git"
 )
 
 // Run exectues the affected tool with the given options
 func Run(opts *Options) error {
-	// Figure out module path from go.mod if not provided by the user
-	if opts.Module == "" {
-		m, err := module.Path()
-		if err != nil {
+	started := time.Now()
+
+	// The legacy JSON output only names the modified packages, not the files that changed
+	opts.ChangeDetails = opts.Format == "text" || !opts.LegacyOutput
+
+	analysis, err := Analyse(opts)
+	if err != nil {
+		return err
+	}
+
+	// The budget is checked after writing the output, fail on an unusable budget before writing
+	if _, err := Budget(opts); err != nil {
+		return err
+	}
+
+	pkgs, err := ClassifiedPackages(opts, analysis)
+	if err != nil {
+		return err
+	}
+
+	var groups affected.Groups
+
+	// If we are grouping group packages by the grouping function, groups are sharded rather than
+	// their packages
+	fn := GroupFunc(opts)
+	if fn != nil {
+		groups, err = ShardGroups(opts, analysis.Graph, affected.GroupPackages(fn, pkgs...))
+
+		if opts.Shard != "" {
+			pkgs = GroupedPackages(groups)
+		}
+	} else {
+		pkgs, err = ShardPackages(opts, analysis.Graph, pkgs)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var v interface{} = affected.List(pkgs)
+	if fn != nil {
+		v = groups
+	}
+
+	if !opts.LegacyOutput && (opts.Format == "json" || opts.Format == "json-minified") {
+		if v, err = Report(opts, started, analysis, pkgs, groups); err != nil {
 			return err
 		}
+	}
+
+	// Write the value to the correct format to the given writer
+	w := Writer(opts)
+	switch opts.Format {
+	case "json":
+		err = WriteJSON(w, v, true)
+	case "json-minified":
+		err = WriteJSON(w, v, false)
+	case "text":
+		err = WriteText(w, v)
+
+		// Packages that failed to load may under-report impact so list them after the packages
+		if err == nil && len(analysis.Errors) > 0 {
+			err = WriteText(w, affected.LoadErrors(analysis.Errors))
+		}
+	default:
+		err = errors.New("unsupported format")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The budget is checked after writing the output so the affected packages are still reported
+	return CheckBudget(opts, analysis)
+}
+
+// GroupedPackages returns the packages in the groups sorted by ID
+func GroupedPackages(groups affected.Groups) []affected.Package {
+	var pkgs []affected.Package
+
+	for _, g := range groups {
+		pkgs = append(pkgs, g.Packages...)
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ID < pkgs[j].ID
+	})
+
+	return pkgs
+}
+
+// CheckBudget checks the affected packages and groups are within the budgets given by the CLI
+// arguments or configuration file, if a budget is exceeded an ExitError with BudgetExitCode is
+// returned
+func CheckBudget(opts *Options, analysis *affected.Analysis) error {
+	b, err := Budget(opts)
+	if err != nil {
+		return err
+	}
+
+	if err := analysis.Check(b, GroupFunc(opts)); err != nil {
+		return &ExitError{Code: BudgetExitCode, Msg: err.Error()}
+	}
 
-		opts.Module = m
+	return nil
+}
+
+// Budget returns the budget given by the CLI arguments or configuration file. Groups are only
+// counted when packages are grouped, so a group budget without --pkg-prefix is an error rather than
+// a budget that is never checked
+func Budget(opts *Options) (affected.Budget, error) {
+	b := affected.Budget{
+		Packages: opts.MaxAffected,
+		Groups:   opts.MaxAffectedGroups,
 	}
 
-	popts := []affected.PackagesOption{}
+	if b.Groups > 0 && GroupFunc(opts) == nil {
+		return b, errors.New("a maximum number of affected groups requires grouping packages with --pkg-prefix")
+	}
+
+	return b, nil
+}
+
+// Analyse analyses the module for affected packages using the given options
+func Analyse(opts *Options) (*affected.Analysis, error) {
+	// Fail on conflicting filters before doing any work
+	if _, err := Classification(opts); err != nil {
+		return nil, err
+	}
+
+	if err := ResolveModule(opts); err != nil {
+		return nil, err
+	}
+
+	cfg, err := LoadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Budgets given on the command line take precedence over the configuration file
+	if opts.MaxAffected == 0 {
+		opts.MaxAffected = cfg.MaxAffected
+	}
+
+	if opts.MaxAffectedGroups == 0 {
+		opts.MaxAffectedGroups = cfg.MaxAffectedGroups
+	}
+
+	opts.NoEmbed = opts.NoEmbed || cfg.NoEmbed
+
+	popts := []affected.PackagesOption{
+		affected.WithImportPathLimit(opts.ImportPaths),
+		affected.WithStrict(opts.Strict && !opts.AllowErrors),
+		affected.WithMaxDepth(MaxDepth(opts)),
+	}
+
+	popts = append(popts, affected.WithAppendTriggers(Triggers(opts, cfg)...))
+
+	popts = append(popts, affected.WithDependencies(Dependencies(cfg)...))
+
+	popts = append(popts, affected.WithFirewalls(Firewalls(opts, cfg)...))
+
+	popts = append(popts, affected.WithProtos(!opts.NoProto), affected.WithGenerate(!opts.NoGenerate))
+
+	popts = append(popts, affected.WithEmbeds(!opts.NoEmbed), affected.WithChangeDetails(opts.ChangeDetails))
+
+	if len(opts.ProtoRoots) > 0 || len(cfg.ProtoRoots) > 0 {
+		roots := append(append([]string{}, opts.ProtoRoots...), cfg.Paths(cfg.ProtoRoots)...)
+		popts = append(popts, affected.WithProtoRoots(roots...))
+	}
+
+	if opts.NearestPackage || cfg.NearestPackage {
+		popts = append(popts, affected.WithNearestPackage())
+	}
+
+	if len(opts.GroupRoots) > 0 || len(cfg.GroupRoots) > 0 {
+		roots := append(append([]string{}, opts.GroupRoots...), cfg.Globs(cfg.GroupRoots)...)
+		popts = append(popts, affected.WithGroupRoots(roots...))
+	}
+
+	loader, err := Loader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	popts = append(popts, affected.WithPackageLoader(loader))
 
 	if len(opts.IncludeGlobs) > 0 {
 		fn := affected.WithAppendIncludeGlobs(opts.IncludeGlobs...)
@@ -39,29 +221,173 @@ func Run(opts *Options) error {
 		popts = append(popts, fn)
 	}
 
-	// Load affected packages
-	pkgs, err := affected.Packages(opts.Module, opts.CommitA, opts.CommitB, popts...)
+	analysis, err := affected.Analyse(opts.Module, opts.CommitA, opts.CommitB, popts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Packages that failed to load may under-report impact so always make the user aware of them
+	for _, e := range analysis.Errors {
+		fmt.Fprintln(os.Stderr, "warning:", e)
+	}
+
+	return analysis, nil
+}
+
+// ResolveModule figures out the module path from go.mod if not provided by the user
+func ResolveModule(opts *Options) error {
+	if opts.Module != "" {
+		return nil
+	}
+
+	m, err := module.Path()
 	if err != nil {
 		return err
 	}
 
-	var v interface{} = pkgs
+	opts.Module = m
 
-	// If we are grouping group packages by the grouping function
-	if fn := GroupFunc(opts); fn != nil {
-		v = affected.GroupPackages(fn, pkgs...)
+	return nil
+}
+
+// Loader returns the package loader selected by the CLI arguments, wrapped with the on-disk package
+// cache unless it is disabled
+func Loader(opts *Options) (module.PackageLoader, error) {
+	loader, err := PackageLoader(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	// Write the value to the correct format to the given writer
-	w := Writer(opts)
-	switch opts.Format {
-	case "json":
-		return WriteJSON(w, v, true)
-	case "json-minified":
-		return WriteJSON(w, v, false)
-	case "text":
-		return WriteText(w, v)
+	if opts.NoCache {
+		return loader, nil
+	}
+
+	c, err := module.NewPackageCache(opts.Module)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Loader(loader), nil
+}
+
+// ClassifiedPackages returns the affected packages to output, filtered by --only-direct or
+// --only-transitive. The analysis is left complete so budgets and explanations see every package.
+func ClassifiedPackages(opts *Options, analysis *affected.Analysis) ([]affected.Package, error) {
+	c, err := Classification(opts)
+	if err != nil || c == "" {
+		return analysis.Packages, err
+	}
+
+	return affected.Classified(c, analysis.Packages...), nil
+}
+
+// Classification returns the classification of the packages to report, empty when neither
+// --only-direct nor --only-transitive is given
+func Classification(opts *Options) (affected.Classification, error) {
+	switch {
+	case opts.OnlyDirect && opts.OnlyTransitive:
+		return "", errors.New("--only-direct and --only-transitive cannot be used together")
+	case opts.OnlyDirect:
+		return affected.Direct, nil
+	case opts.OnlyTransitive:
+		return affected.Transitive, nil
+	}
+
+	return "", nil
+}
+
+// MaxDepth returns the maximum number of import hops changes propagate, --direct-only takes
+// precedence over --max-depth
+func MaxDepth(opts *Options) int {
+	if opts.DirectOnly {
+		return 1
+	}
+
+	return opts.MaxDepth
+}
+
+// PackageLoader returns the package loader selected by the CLI arguments
+func PackageLoader(opts *Options) (module.PackageLoader, error) {
+	switch opts.Loader {
+	case "golist":
+		return module.GoListPackageLoader(), nil
+	case "packages":
+		return module.TypesPackageLoader(), nil
 	default:
-		return errors.New("unsupported format")
+		return nil, errors.New("unsupported loader")
+	}
+}
+
+// LoadConfig loads the configuration file. The default file is optional and read from the root of
+// the repository, falling back to the working directory outside a repository
+func LoadConfig(opts *Options) (*config.Config, error) {
+	if opts.Config != config.DefaultFile {
+		return config.Load(opts.Config, false)
+	}
+
+	path := config.DefaultFile
+
+	if g, err := git.New(); err == nil {
+		path = filepath.Join(g.RepositoryDir, config.DefaultFile)
+	}
+
+	return config.Load(path, true)
+}
+
+// Triggers returns the user defined global triggers from the CLI arguments and configuration file
+func Triggers(opts *Options, cfg *config.Config) []affected.Trigger {
+	triggers := make([]affected.Trigger, 0, len(opts.Triggers)+len(cfg.Triggers))
+
+	for _, g := range opts.Triggers {
+		triggers = append(triggers, affected.Trigger{Name: g, Globs: []string{g}})
 	}
+
+	for _, t := range cfg.Triggers {
+		name := t.Name
+		if name == "" && len(t.Files) > 0 {
+			name = t.Files[0]
+		}
+
+		triggers = append(triggers, affected.Trigger{Name: name, Globs: cfg.Globs(t.Files)})
+	}
+
+	return triggers
+}
+
+// Dependencies returns the dependencies on non-Go files from the configuration file
+func Dependencies(cfg *config.Config) []affected.Dependency {
+	deps := make([]affected.Dependency, 0, len(cfg.Dependencies))
+
+	for _, d := range cfg.Dependencies {
+		name := d.Name
+		if name == "" && len(d.Files) > 0 {
+			name = d.Files[0]
+		}
+
+		deps = append(deps, affected.Dependency{
+			Name:     name,
+			Globs:    cfg.Globs(d.Files),
+			Packages: d.Packages,
+		})
+	}
+
+	return deps
+}
+
+// Firewalls returns the firewall packages from the CLI arguments and configuration file
+func Firewalls(opts *Options, cfg *config.Config) []affected.Firewall {
+	firewalls := make([]affected.Firewall, 0, len(cfg.Firewalls)+1)
+
+	if len(opts.Firewalls) > 0 {
+		firewalls = append(firewalls, affected.Firewall{Packages: opts.Firewalls})
+	}
+
+	for _, f := range cfg.Firewalls {
+		firewalls = append(firewalls, affected.Firewall{
+			Packages:  f.Packages,
+			Importers: f.Importers,
+		})
+	}
+
+	return firewalls
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/affected"
+)
+
+func TestClassifiedPackages(t *testing.T) {
+	analysis := testAnalysis()
+
+	testCases := map[string]struct {
+		opts     Options
+		expected []string
+		err      bool
+	}{
+		"All": {
+			expected: []string{"foo.com/cmd/a", "foo.com/pkg/log"},
+		},
+		"OnlyDirect": {
+			opts:     Options{OnlyDirect: true},
+			expected: []string{"foo.com/pkg/log"},
+		},
+		"OnlyTransitive": {
+			opts:     Options{OnlyTransitive: true},
+			expected: []string{"foo.com/cmd/a"},
+		},
+		"Both": {
+			opts: Options{OnlyDirect: true, OnlyTransitive: true},
+			err:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pkgs, err := ClassifiedPackages(&tc.opts, analysis)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			ids := make([]string, len(pkgs))
+			for i, pkg := range pkgs {
+				ids[i] = pkg.ID
+			}
+
+			assert.Equal(t, tc.expected, ids)
+
+			// The filter only changes the output, budgets and explanations still see every package
+			assert.Len(t, analysis.Packages, 2)
+			assert.True(t, analysis.Why(analysis.Graph.Lookup("foo.com/cmd/a")).Affected)
+
+			opts := tc.opts
+			opts.MaxAffected = 1
+
+			err = CheckBudget(&opts, analysis)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "2 packages affected")
+		})
+	}
+}
+
+func TestBudget(t *testing.T) {
+	testCases := map[string]struct {
+		opts Options
+		err  bool
+	}{
+		"None": {},
+		"Packages": {
+			opts: Options{MaxAffected: 1},
+		},
+		"Groups": {
+			opts: Options{MaxAffectedGroups: 1, GroupByPkgPrefix: "foo.com"},
+		},
+		"GroupsWithoutGrouping": {
+			opts: Options{MaxAffectedGroups: 1, GroupRoots: []string{"services/*"}},
+			err:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := Budget(&tc.opts)
+			if tc.err {
+				assert.Error(t, err)
+				assert.Error(t, CheckBudget(&tc.opts, &affected.Analysis{}))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, affected.Budget{Packages: tc.opts.MaxAffected, Groups: tc.opts.MaxAffectedGroups}, b)
+		})
+	}
+}
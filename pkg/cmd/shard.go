@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/shard"
+)
+
+// DefaultTimingsFile is the default timings file, relative to the working directory
+const DefaultTimingsFile = ".affected-timings.json"
+
+// SelectShard returns the names of the targets in the shard selected by the CLI arguments, targets
+// maps the name of each target to the IDs of its packages. Targets are weighted by their historical
+// duration from the timings file, targets without a timing are weighted by the mean duration. If
+// there are no timings targets are weighted by their number of Go files. ok is false when no shard
+// is selected.
+func SelectShard(opts *Options, g *module.Graph, targets map[string][]string) (map[string]struct{}, bool, error) {
+	if opts.Shard == "" {
+		return nil, false, nil
+	}
+
+	s, err := shard.Parse(opts.Shard)
+	if err != nil {
+		return nil, false, err
+	}
+
+	timings, err := shard.LoadTimings(opts.Timings, opts.Timings == DefaultTimingsFile)
+	if err != nil {
+		return nil, false, err
+	}
+
+	items := make([]shard.Item, 0, len(targets))
+	timed := make(map[string]bool, len(targets))
+
+	var (
+		sum float64
+		n   int
+	)
+
+	// Weights are summed in name order, float addition is not associative so summing in map order
+	// could give each worker a different mean and a different split
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		w, ok := timings.Weight(name, targets[name]...)
+		if ok {
+			sum += w
+			n++
+		}
+
+		timed[name] = ok
+		items = append(items, shard.Item{Name: name, Weight: w})
+	}
+
+	for i, item := range items {
+		switch {
+		case n == 0:
+			items[i].Weight = float64(files(g, targets[item.Name]...))
+		case !timed[item.Name]:
+			items[i].Weight = sum / float64(n)
+		}
+	}
+
+	selected := make(map[string]struct{})
+	for _, name := range s.Select(items) {
+		selected[name] = struct{}{}
+	}
+
+	return selected, true, nil
+}
+
+// files returns the number of Go files in the packages
+func files(g *module.Graph, ids ...string) int {
+	var n int
+
+	for _, id := range ids {
+		if pkg := g.Lookup(id); pkg != nil {
+			n += len(pkg.Files())
+		}
+	}
+
+	return n
+}
+
+// ShardPackages returns the affected packages in the shard selected by the CLI arguments
+func ShardPackages(opts *Options, g *module.Graph, pkgs []affected.Package) ([]affected.Package, error) {
+	targets := make(map[string][]string, len(pkgs))
+	for _, pkg := range pkgs {
+		targets[pkg.ID] = []string{pkg.ID}
+	}
+
+	selected, ok, err := SelectShard(opts, g, targets)
+	if err != nil || !ok {
+		return pkgs, err
+	}
+
+	out := make([]affected.Package, 0, len(selected))
+
+	for _, pkg := range pkgs {
+		if _, ok := selected[pkg.ID]; ok {
+			out = append(out, pkg)
+		}
+	}
+
+	return out, nil
+}
+
+// ShardGroups returns the groups in the shard selected by the CLI arguments
+func ShardGroups(opts *Options, g *module.Graph, groups affected.Groups) (affected.Groups, error) {
+	targets := make(map[string][]string, len(groups))
+
+	for _, group := range groups {
+		for _, pkg := range group.Packages {
+			targets[group.Name] = append(targets[group.Name], pkg.ID)
+		}
+	}
+
+	selected, ok, err := SelectShard(opts, g, targets)
+	if err != nil || !ok {
+		return groups, err
+	}
+
+	out := make(affected.Groups, 0, len(selected))
+
+	for _, group := range groups {
+		if _, ok := selected[group.Name]; ok {
+			out = append(out, group)
+		}
+	}
+
+	return out, nil
+}
+
+// TimingsCmd returns the timings sub command which manages the timings file used to balance shards
+func TimingsCmd(opts *Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "timings",
+		Short: "Manage the timings file used to balance shards",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "record",
+		Short:   "Record package durations from go test -json output read from stdin",
+		Example: "go test -json ./... | affected timings record --timings .affected-timings.json",
+		Args:    cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			timings, err := shard.LoadTimings(opts.Timings, true)
+			if err != nil {
+				return err
+			}
+
+			if _, err := timings.Record(os.Stdin); err != nil {
+				return err
+			}
+
+			return timings.Save(opts.Timings)
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/shard"
+)
+
+func TestSelectShard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timings.json")
+
+	timings := shard.Timings{}
+	targets := make(map[string][]string)
+
+	// Timings whose sum depends on the order they are added in, with untimed targets weighted by
+	// their mean
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("foo.com/pkg/%02d", i)
+		targets[name] = []string{name}
+
+		if i%2 == 0 {
+			timings[name] = 0.1 * float64(i%7+1)
+		}
+	}
+
+	require.NoError(t, timings.Save(path))
+
+	all := make(map[string]struct{})
+
+	for i := 1; i <= 3; i++ {
+		opts := &Options{Shard: fmt.Sprintf("%d/3", i), Timings: path}
+
+		expected, ok, err := SelectShard(opts, nil, targets)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		// Every worker must select the same targets however the map is iterated
+		for n := 0; n < 20; n++ {
+			actual, _, err := SelectShard(opts, nil, targets)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+
+		for name := range expected {
+			all[name] = struct{}{}
+		}
+	}
+
+	assert.Len(t, all, len(targets))
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// WhyCmd returns the why sub command which explains why a single package is, or is not, affected
+func WhyCmd(opts *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "why <import-path-or-dir>",
+		Short:   "Explain why a package is, or is not, affected",
+		Long:    "Explain whether a package is affected between commit A and B, listing the changed files and import paths that caused it.",
+		Example: "affected why ./services/billing -a origin/master -b HEAD -f text",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return Why(opts, args[0])
+		},
+	}
+}
+
+// Why explains why the package, given by import path or directory, is or is not affected
+func Why(opts *Options, pkg string) error {
+	opts.ChangeDetails = true
+
+	analysis, err := Analyse(opts)
+	if err != nil {
+		return err
+	}
+
+	p := LookupPackage(analysis.Graph, pkg)
+	if p == nil {
+		return fmt.Errorf("package %s not found", pkg)
+	}
+
+	e := analysis.Why(p)
+
+	w := Writer(opts)
+	switch opts.Format {
+	case "json":
+		return WriteJSON(w, e, true)
+	case "json-minified":
+		return WriteJSON(w, e, false)
+	case "text":
+		return WriteText(w, e)
+	default:
+		return errors.New("unsupported format")
+	}
+}
+
+// LookupPackage looks up a package in the graph by import path, or by directory if no package has
+// the import path
+func LookupPackage(g *module.Graph, pkg string) *module.Package {
+	if p := g.Lookup(pkg); p != nil {
+		return p
+	}
+
+	dir, err := filepath.Abs(pkg)
+	if err != nil {
+		return nil
+	}
+
+	if e, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = e
+	}
+
+	return g.Find(module.FindPackageByDir(dir))
+}
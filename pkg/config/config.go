@@ -0,0 +1,119 @@
+// Package config loads the repository level configuration file, which lets teams check in settings
+// that would otherwise need to be passed as flags on every run.
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultFile is the default configuration file name, relative to the root of the repository
+const DefaultFile = ".affected.yaml"
+
+// Config is the repository configuration
+type Config struct {
+	Triggers          []Trigger    `yaml:"triggers"`          // User defined global triggers
+	NearestPackage    bool         `yaml:"nearestPackage"`    // Resolve files outside packages to their nearest ancestor package
+	GroupRoots        []string     `yaml:"groupRoots"`        // Directory globs, relative to the configuration file unless absolute
+	Dependencies      []Dependency `yaml:"dependencies"`      // Non-Go files packages depend upon
+	ProtoRoots        []string     `yaml:"protoRoots"`        // Directories protobuf files are found in, relative to the configuration file
+	Firewalls         []Firewall   `yaml:"firewalls"`         // Packages whose changes do not propagate to their importers
+	MaxAffected       int          `yaml:"maxAffected"`       // Maximum number of affected packages before the budget is exceeded
+	MaxAffectedGroups int          `yaml:"maxAffectedGroups"` // Maximum number of affected groups before the budget is exceeded
+	NoEmbed           bool         `yaml:"noEmbed"`           // Do not mark packages as modified when a file they embed changes
+
+	dir string // Directory the configuration file is in
+}
+
+// A Trigger is a user defined global trigger, when a file matching one of its globs changes every
+// package is marked as affected
+type Trigger struct {
+	Name  string   `yaml:"name"`  // Name of the trigger, defaults to the first glob
+	Files []string `yaml:"files"` // File globs, relative to the configuration file unless absolute
+}
+
+// A Dependency declares that packages depend upon non-Go files, a change to a matching file affects
+// the packages and their importers
+type Dependency struct {
+	Name     string   `yaml:"name"`     // Name of the dependency, defaults to the first glob
+	Files    []string `yaml:"files"`    // File globs, relative to the configuration file unless absolute
+	Packages []string `yaml:"packages"` // Package import paths, paths ending in /... include sub packages
+}
+
+// A Firewall stops changes to its packages propagating to their importers
+type Firewall struct {
+	Packages  []string `yaml:"packages"`  // Package import paths, paths ending in /... include sub packages
+	Importers []string `yaml:"importers"` // Import paths of the importers changes stop at, a path ending in /... matches those beneath it, empty stops them for every importer
+}
+
+// Load loads the configuration file, if the file does not exist and is optional an empty
+// configuration is returned
+func Load(path string, optional bool) (*Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{dir: filepath.Dir(abs)}
+
+	// Changed files have symlinks resolved, so the directory globs are resolved against must too
+	if dir, err := filepath.EvalSymlinks(c.dir); err == nil {
+		c.dir = dir
+	} else if !optional || !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadFile(abs)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return c, nil
+		}
+
+		return nil, err
+	}
+
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Glob resolves a glob in the configuration file to an absolute glob, globs that start with a
+// slash are already absolute, e.g /**/Makefile
+func (c *Config) Glob(glob string) string {
+	if strings.HasPrefix(glob, "/") {
+		return glob
+	}
+
+	return filepath.ToSlash(c.dir) + "/" + strings.TrimPrefix(glob, "./")
+}
+
+// Globs resolves each glob with Glob
+func (c *Config) Globs(globs []string) []string {
+	out := make([]string, len(globs))
+
+	for i, g := range globs {
+		out[i] = c.Glob(g)
+	}
+
+	return out
+}
+
+// Paths resolves relative paths in the configuration file against the directory it is in
+func (c *Config) Paths(paths []string) []string {
+	out := make([]string, len(paths))
+
+	for i, p := range paths {
+		out[i] = p
+		if !filepath.IsAbs(p) {
+			out[i] = filepath.Join(c.dir, p)
+		}
+	}
+
+	return out
+}
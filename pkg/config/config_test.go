@@ -0,0 +1,124 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "affected-config")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmp)
+
+	dir, err := filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, DefaultFile)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+triggers:
+  - name: build tooling
+    files:
+      - Makefile
+      - ./scripts/build.sh
+      - /**/.golangci.yml
+nearestPackage: true
+groupRoots:
+  - services/*
+dependencies:
+  - files:
+      - migrations/billing/**
+    packages:
+      - foo.com/services/billing
+protoRoots:
+  - proto
+  - /opt/proto
+maxAffected: 50
+maxAffectedGroups: 5
+noEmbed: true
+firewalls:
+  - packages:
+      - foo.com/pkg/log
+    importers:
+      - foo.com/services/...
+`), 0644))
+
+	c, err := Load(path, false)
+	require.NoError(t, err)
+	require.Len(t, c.Triggers, 1)
+
+	assert.Equal(t, "build tooling", c.Triggers[0].Name)
+	assert.Equal(t, []string{
+		dir + "/Makefile",
+		dir + "/scripts/build.sh",
+		"/**/.golangci.yml",
+	}, c.Globs(c.Triggers[0].Files))
+
+	assert.True(t, c.NearestPackage)
+	assert.True(t, c.NoEmbed)
+	assert.Equal(t, []string{dir + "/services/*"}, c.Globs(c.GroupRoots))
+
+	require.Len(t, c.Dependencies, 1)
+	assert.Equal(t, []string{dir + "/migrations/billing/**"}, c.Globs(c.Dependencies[0].Files))
+	assert.Equal(t, []string{"foo.com/services/billing"}, c.Dependencies[0].Packages)
+
+	assert.Equal(t, []string{filepath.Join(dir, "proto"), "/opt/proto"}, c.Paths(c.ProtoRoots))
+
+	assert.Equal(t, 50, c.MaxAffected)
+	assert.Equal(t, 5, c.MaxAffectedGroups)
+
+	assert.Equal(t, []Firewall{{
+		Packages:  []string{"foo.com/pkg/log"},
+		Importers: []string{"foo.com/services/..."},
+	}}, c.Firewalls)
+}
+
+func TestLoadMissing(t *testing.T) {
+	c, err := Load(filepath.Join(os.TempDir(), "affected-missing", DefaultFile), true)
+	require.NoError(t, err)
+	assert.Empty(t, c.Triggers)
+
+	_, err = Load(filepath.Join(os.TempDir(), "affected-missing", DefaultFile), false)
+	assert.Error(t, err)
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	f, err := ioutil.TempFile("", "affected-config")
+	require.NoError(t, err)
+
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("trigers: []\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = Load(f.Name(), false)
+	assert.Error(t, err)
+}
+
+func TestLoadSymlink(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "affected-config")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(tmp)
+
+	dir, err := filepath.EvalSymlinks(tmp)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "repo"), 0755))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "repo"), filepath.Join(dir, "link")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "repo", DefaultFile), []byte("protoRoots: [proto]\n"), 0644))
+
+	// Globs and paths are resolved against the real directory, like the changed files they match
+	c, err := Load(filepath.Join(dir, "link", DefaultFile), false)
+	require.NoError(t, err)
+
+	assert.Equal(t, dir+"/repo/Makefile", c.Glob("Makefile"))
+	assert.Equal(t, []string{filepath.Join(dir, "repo", "proto")}, c.Paths(c.ProtoRoots))
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// dotStyles are the node attributes used for each node kind
+var dotStyles = map[NodeKind]string{
+	Unaffected: `style=filled, fillcolor="#ffffff"`,
+	Affected:   `style=filled, fillcolor="#ffe08a"`,
+	Modified:   `style=filled, fillcolor="#ff8a80"`,
+}
+
+// DOT writes the graph in the Graphviz DOT language
+func DOT(w io.Writer, g *Graph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph affected {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, "  node [shape=box];")
+
+	for _, n := range g.Nodes {
+		label := n.ID
+		if len(n.Packages) > 0 {
+			label = fmt.Sprintf("%s (%d packages)", n.ID, len(n.Packages))
+		}
+
+		fmt.Fprintf(bw, "  %s [label=%s, %s];\n", strconv.Quote(n.ID), strconv.Quote(label), dotStyles[n.Kind])
+	}
+
+	for _, e := range g.Edges {
+		attrs := ""
+		if e.Cause {
+			attrs = ` [color="#d50000", penwidth=2]`
+		}
+
+		fmt.Fprintf(bw, "  %s -> %s%s;\n", strconv.Quote(e.From), strconv.Quote(e.To), attrs)
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
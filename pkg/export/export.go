@@ -0,0 +1,231 @@
+// Package export renders the package import graph, or the affected part of it, into formats that
+// can be visualised such as Graphviz DOT and Mermaid flowcharts.
+package export
+
+import (
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// NodeKind describes how a node has been affected
+type NodeKind int8
+
+// Node kinds, ordered so that a collapsed group takes the greatest kind of its members
+const (
+	Unaffected NodeKind = iota
+	Affected
+	Modified
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case Affected:
+		return "affected"
+	case Modified:
+		return "modified"
+	default:
+		return "unaffected"
+	}
+}
+
+// MarshalText marshals the node kind to its string form
+func (k NodeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// A Node is a package, or a group of packages, in the exported graph
+type Node struct {
+	ID       string   `json:"id"`                 // Package import path or group name
+	Kind     NodeKind `json:"kind"`               // How the node has been affected
+	Packages []string `json:"packages,omitempty"` // Packages collapsed into the node
+}
+
+// An Edge is an import from one node to another
+type Edge struct {
+	From  string `json:"from"`  // Importing node
+	To    string `json:"to"`    // Imported node
+	Cause bool   `json:"cause"` // Edge is part of an import path that caused a package to be affected
+}
+
+// Graph is an exportable graph of nodes and edges
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Options configures the exported graph
+type Options struct {
+	AffectedOnly bool               // Only export affected packages and the edges that caused them
+	Group        affected.GroupFunc // Collapse packages into groups, may be nil
+	Packages     []*module.Package  // Only export these packages, all packages are exported if nil
+}
+
+// Option configures export options
+type Option func(*Options)
+
+// WithAffectedOnly only exports the affected subgraph
+func WithAffectedOnly() Option {
+	return func(o *Options) {
+		o.AffectedOnly = true
+	}
+}
+
+// WithPackages only exports the given packages and the imports between them, no packages are
+// exported if none are given
+func WithPackages(pkgs ...*module.Package) Option {
+	return func(o *Options) {
+		if o.Packages == nil {
+			o.Packages = make([]*module.Package, 0, len(pkgs))
+		}
+
+		o.Packages = append(o.Packages, pkgs...)
+	}
+}
+
+// WithGroups collapses packages into the groups returned by the GroupFunc, packages that are not
+// grouped are left as they are
+func WithGroups(fn affected.GroupFunc) Option {
+	return func(o *Options) {
+		o.Group = fn
+	}
+}
+
+// New builds an exportable graph from the analysis
+func New(analysis *affected.Analysis, opts ...Option) *Graph {
+	o := &Options{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	kinds := make(map[*module.Package]NodeKind)
+	causes := make(map[[2]*module.Package]struct{})
+
+	for _, pkg := range analysis.Packages {
+		kinds[pkg.Package] = Affected
+
+		for _, cause := range pkg.Causes {
+			paths := cause.ImportPaths
+			if len(paths) == 0 {
+				paths = []module.ImportPath{cause.ImportPath}
+			}
+
+			for _, path := range paths {
+				for i := 1; i < len(path); i++ {
+					causes[[2]*module.Package{path[i-1], path[i]}] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for _, pkg := range append(append([]*module.Package{}, analysis.Modified...), analysis.Files...) {
+		kinds[pkg] = Modified
+	}
+
+	b := &builder{
+		group: o.Group,
+		nodes: make(map[string]int),
+		edges: make(map[[2]string]int),
+		graph: &Graph{},
+	}
+
+	var only map[*module.Package]struct{}
+
+	if o.Packages != nil {
+		only = make(map[*module.Package]struct{}, len(o.Packages))
+		for _, pkg := range o.Packages {
+			only[pkg] = struct{}{}
+		}
+	}
+
+	for _, pkg := range analysis.Graph.Packages() {
+		kind, ok := kinds[pkg]
+		if o.AffectedOnly && !ok {
+			continue
+		}
+
+		if _, ok := only[pkg]; only != nil && !ok {
+			continue
+		}
+
+		b.node(pkg, kind)
+	}
+
+	for _, pkg := range analysis.Graph.Packages() {
+		for _, imp := range pkg.Imports {
+			_, cause := causes[[2]*module.Package{pkg, imp}]
+
+			if o.AffectedOnly && !cause {
+				continue
+			}
+
+			b.edge(pkg, imp, cause)
+		}
+	}
+
+	return b.graph
+}
+
+type builder struct {
+	group affected.GroupFunc
+	nodes map[string]int    // Index of nodes in the graph by ID
+	edges map[[2]string]int // Index of edges in the graph by their from and to IDs
+	graph *Graph
+}
+
+// id returns the node ID for the package, taking grouping into account
+func (b *builder) id(pkg *module.Package) string {
+	if b.group != nil {
+		if name, ok := b.group(&affected.Package{Package: pkg}); ok {
+			return name
+		}
+	}
+
+	return pkg.ID
+}
+
+func (b *builder) node(pkg *module.Package, kind NodeKind) {
+	id := b.id(pkg)
+
+	i, ok := b.nodes[id]
+	if !ok {
+		i = len(b.graph.Nodes)
+		b.nodes[id] = i
+		b.graph.Nodes = append(b.graph.Nodes, Node{ID: id})
+	}
+
+	n := &b.graph.Nodes[i]
+
+	if kind > n.Kind {
+		n.Kind = kind
+	}
+
+	if id != pkg.ID {
+		n.Packages = append(n.Packages, pkg.ID)
+	}
+}
+
+func (b *builder) edge(from, to *module.Package, cause bool) {
+	key := [2]string{b.id(from), b.id(to)}
+
+	if key[0] == key[1] {
+		return
+	}
+
+	if _, ok := b.nodes[key[0]]; !ok {
+		return
+	}
+
+	if _, ok := b.nodes[key[1]]; !ok {
+		return
+	}
+
+	i, ok := b.edges[key]
+	if !ok {
+		i = len(b.graph.Edges)
+		b.edges[key] = i
+		b.graph.Edges = append(b.graph.Edges, Edge{From: key[0], To: key[1]})
+	}
+
+	b.graph.Edges[i].Cause = b.graph.Edges[i].Cause || cause
+}
@@ -0,0 +1,225 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestNew(t *testing.T) {
+	raw := func(id string, imports ...string) *packages.Package {
+		pkg := &packages.Package{ID: id, Imports: make(map[string]*packages.Package)}
+		for _, imp := range imports {
+			pkg.Imports[imp] = &packages.Package{ID: imp}
+		}
+
+		return pkg
+	}
+
+	graph := module.NewGraph(
+		raw("foo.com/services/a/cmd", "foo.com/services/a/api"),
+		raw("foo.com/services/a/api", "foo.com/pkg/log"),
+		raw("foo.com/services/b/cmd", "foo.com/pkg/log", "foo.com/pkg/util"),
+		raw("foo.com/pkg/log"),
+		raw("foo.com/pkg/util"))
+
+	// foo.com/pkg/log is modified and imported by both services
+	log := graph.Lookup("foo.com/pkg/log")
+
+	analysis := &affected.Analysis{
+		Graph:    graph,
+		Modified: []*module.Package{log},
+	}
+
+	for _, path := range graph.ImportPaths(log) {
+		analysis.Packages = append(analysis.Packages, affected.Package{
+			Package: path[0],
+			Causes:  []affected.Cause{{Package: log, ImportPath: path}},
+		})
+	}
+
+	testCases := map[string]struct {
+		opts  []Option
+		nodes map[string]NodeKind
+		edges map[[2]string]bool
+	}{
+		"ExportsWholeGraph": {
+			nodes: map[string]NodeKind{
+				"foo.com/services/a/cmd": Affected,
+				"foo.com/services/a/api": Affected,
+				"foo.com/services/b/cmd": Affected,
+				"foo.com/pkg/log":        Modified,
+				"foo.com/pkg/util":       Unaffected,
+			},
+			edges: map[[2]string]bool{
+				{"foo.com/services/a/cmd", "foo.com/services/a/api"}: true,
+				{"foo.com/services/a/api", "foo.com/pkg/log"}:        true,
+				{"foo.com/services/b/cmd", "foo.com/pkg/log"}:        true,
+				{"foo.com/services/b/cmd", "foo.com/pkg/util"}:       false,
+			},
+		},
+		"ExportsAffectedSubgraph": {
+			opts: []Option{WithAffectedOnly()},
+			nodes: map[string]NodeKind{
+				"foo.com/services/a/cmd": Affected,
+				"foo.com/services/a/api": Affected,
+				"foo.com/services/b/cmd": Affected,
+				"foo.com/pkg/log":        Modified,
+			},
+			edges: map[[2]string]bool{
+				{"foo.com/services/a/cmd", "foo.com/services/a/api"}: true,
+				{"foo.com/services/a/api", "foo.com/pkg/log"}:        true,
+				{"foo.com/services/b/cmd", "foo.com/pkg/log"}:        true,
+			},
+		},
+		"CollapsesGroups": {
+			opts: []Option{WithAffectedOnly(), WithGroups(affected.GroupByPkgAfterPrefix("foo.com/services", 0))},
+			nodes: map[string]NodeKind{
+				"foo.com/services/a": Affected,
+				"foo.com/services/b": Affected,
+				"foo.com/pkg/log":    Modified,
+			},
+			edges: map[[2]string]bool{
+				{"foo.com/services/a", "foo.com/pkg/log"}: true,
+				{"foo.com/services/b", "foo.com/pkg/log"}: true,
+			},
+		},
+		"ExportsPackages": {
+			opts: []Option{WithPackages(graph.Lookup("foo.com/services/a/cmd"), graph.Lookup("foo.com/services/a/api"), graph.Lookup("foo.com/pkg/util"))},
+			nodes: map[string]NodeKind{
+				"foo.com/services/a/cmd": Affected,
+				"foo.com/services/a/api": Affected,
+				"foo.com/pkg/util":       Unaffected,
+			},
+			edges: map[[2]string]bool{
+				{"foo.com/services/a/cmd", "foo.com/services/a/api"}: true,
+			},
+		},
+		"ExportsNoPackages": {
+			opts:  []Option{WithPackages()},
+			nodes: map[string]NodeKind{},
+			edges: map[[2]string]bool{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := New(analysis, tc.opts...)
+
+			nodes := make(map[string]NodeKind)
+			for _, n := range g.Nodes {
+				nodes[n.ID] = n.Kind
+			}
+
+			edges := make(map[[2]string]bool)
+			for _, e := range g.Edges {
+				edges[[2]string{e.From, e.To}] = e.Cause
+			}
+
+			assert.Equal(t, tc.nodes, nodes)
+			assert.Equal(t, tc.edges, edges)
+		})
+	}
+}
+
+func TestNewPackagesEmpty(t *testing.T) {
+	// A query matching no packages exports an empty graph rather than every package
+	g := New(&affected.Analysis{Graph: module.NewGraph(&packages.Package{ID: "foo.com/a"})}, WithPackages())
+
+	testCases := map[string]struct {
+		write    func(*bytes.Buffer, *Graph) error
+		expected string
+	}{
+		"DOT": {
+			write: func(w *bytes.Buffer, g *Graph) error { return DOT(w, g) },
+			expected: `digraph affected {
+  rankdir=LR;
+  node [shape=box];
+}
+`,
+		},
+		"Mermaid": {
+			write: func(w *bytes.Buffer, g *Graph) error { return Mermaid(w, g) },
+			expected: `flowchart LR
+  classDef affected fill:#ffe08a,stroke:#333
+  classDef modified fill:#ff8a80,stroke:#333
+`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := new(bytes.Buffer)
+
+			require.NoError(t, tc.write(w, g))
+			assert.Equal(t, tc.expected, w.String())
+		})
+	}
+}
+
+func TestWriters(t *testing.T) {
+	g := &Graph{
+		Nodes: []Node{
+			{ID: "foo.com/a", Kind: Affected},
+			{ID: "foo.com/b", Kind: Modified},
+		},
+		Edges: []Edge{
+			{From: "foo.com/a", To: "foo.com/b", Cause: true},
+		},
+	}
+
+	testCases := map[string]struct {
+		write    func(*bytes.Buffer, *Graph) error
+		expected string
+	}{
+		"DOT": {
+			write: func(w *bytes.Buffer, g *Graph) error { return DOT(w, g) },
+			expected: `digraph affected {
+  rankdir=LR;
+  node [shape=box];
+  "foo.com/a" [label="foo.com/a", style=filled, fillcolor="#ffe08a"];
+  "foo.com/b" [label="foo.com/b", style=filled, fillcolor="#ff8a80"];
+  "foo.com/a" -> "foo.com/b" [color="#d50000", penwidth=2];
+}
+`,
+		},
+		"Mermaid": {
+			write: func(w *bytes.Buffer, g *Graph) error { return Mermaid(w, g) },
+			expected: `flowchart LR
+  classDef affected fill:#ffe08a,stroke:#333
+  classDef modified fill:#ff8a80,stroke:#333
+  n0["foo.com/a"]
+  class n0 affected
+  n1["foo.com/b"]
+  class n1 modified
+  n0 ==> n1
+`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w := new(bytes.Buffer)
+
+			require.NoError(t, tc.write(w, g))
+			assert.Equal(t, tc.expected, w.String())
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Mermaid writes the graph as a Mermaid flowchart, cause edges are drawn as thick links
+func Mermaid(w io.Writer, g *Graph) error {
+	bw := bufio.NewWriter(w)
+
+	// Mermaid node IDs can't contain most punctuation so nodes are numbered and labelled instead
+	ids := make(map[string]string, len(g.Nodes))
+
+	fmt.Fprintln(bw, "flowchart LR")
+	fmt.Fprintln(bw, "  classDef affected fill:#ffe08a,stroke:#333")
+	fmt.Fprintln(bw, "  classDef modified fill:#ff8a80,stroke:#333")
+
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+
+		label := n.ID
+		if len(n.Packages) > 0 {
+			label = fmt.Sprintf("%s (%d packages)", n.ID, len(n.Packages))
+		}
+
+		fmt.Fprintf(bw, "  %s[\"%s\"]\n", id, strings.ReplaceAll(label, `"`, "#quot;"))
+
+		if n.Kind != Unaffected {
+			fmt.Fprintf(bw, "  class %s %s\n", id, n.Kind)
+		}
+	}
+
+	for _, e := range g.Edges {
+		link := "-->"
+		if e.Cause {
+			link = "==>"
+		}
+
+		fmt.Fprintf(bw, "  %s %s %s\n", ids[e.From], link, ids[e.To])
+	}
+
+	return bw.Flush()
+}
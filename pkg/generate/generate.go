@@ -0,0 +1,230 @@
+// Package generate finds //go:generate directives in Go files and works out which input files drive
+// each generation, either from an //affected:inputs annotation or by inferring them from the
+// arguments of common generators such as mockgen, stringer and sqlc.
+package generate
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	generatePrefix = "//go:generate "
+	inputsPrefix   = "//affected:inputs "
+)
+
+// A Directive is a //go:generate directive
+type Directive struct {
+	File   string   // Go file the directive is in
+	Line   int      // Line the directive is on
+	Args   []string // Command and arguments, with $GOFILE and $GOLINE expanded
+	Inputs []string // Absolute globs of the files that drive the generation
+
+	Annotated bool // Inputs come from an //affected:inputs annotation rather than being inferred
+}
+
+// A Scanner finds the //go:generate directives in a package's Go files
+type Scanner interface {
+	Directives(pkg *packages.Package) ([]Directive, error)
+}
+
+// ScannerFunc is an adapter to allow the use of ordinary functions as Scanners
+type ScannerFunc func(pkg *packages.Package) ([]Directive, error)
+
+// Directives calls fn(pkg)
+func (fn ScannerFunc) Directives(pkg *packages.Package) ([]Directive, error) {
+	return fn(pkg)
+}
+
+// FileScanner returns a Scanner that scans every Go file of a package with Scan
+func FileScanner() Scanner {
+	return ScannerFunc(func(pkg *packages.Package) ([]Directive, error) {
+		return Scan(pkg.GoFiles...)
+	})
+}
+
+// Dir returns the directory the directive is run in
+func (d Directive) Dir() string {
+	return filepath.Dir(d.File)
+}
+
+// Tool returns the tool the directive runs, for go run this is the package run without its version
+// and for go tool it is the name of the tool
+func (d Directive) Tool() string {
+	tool, _ := d.tool()
+	return tool
+}
+
+// Uses reports whether the directive runs the tool, tool is the package path of a tool from a go.mod
+// tool directive, e.g go.uber.org/mock/mockgen
+func (d Directive) Uses(tool string) bool {
+	t := d.Tool()
+	if t == "" {
+		return false
+	}
+
+	return t == tool || (!strings.Contains(t, "/") && t == path.Base(tool))
+}
+
+// tool returns the tool the directive runs and the index of its first argument
+func (d Directive) tool() (string, int) {
+	if len(d.Args) == 0 {
+		return "", 0
+	}
+
+	if d.Args[0] != "go" || len(d.Args) < 3 {
+		return d.Args[0], 1
+	}
+
+	switch d.Args[1] {
+	case "tool":
+		return d.Args[2], 3
+	case "run":
+		// Skip flags to go run, e.g go run -mod=mod foo.com/gen
+		for i := 2; i < len(d.Args); i++ {
+			if !strings.HasPrefix(d.Args[i], "-") {
+				return strings.SplitN(d.Args[i], "@", 2)[0], i + 1
+			}
+		}
+	}
+
+	return d.Args[0], 1
+}
+
+// Scan scans the Go files for //go:generate directives. Inputs annotated with an //affected:inputs
+// comment, relative to the file's directory, apply to the next directive in the file and are used
+// in place of inferred inputs. Files that do not exist are skipped.
+func Scan(files ...string) ([]Directive, error) {
+	var directives []Directive
+
+	for _, file := range files {
+		d, err := scanFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		directives = append(directives, d...)
+	}
+
+	return directives, nil
+}
+
+func scanFile(file string) ([]Directive, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var (
+		directives []Directive
+		annotated  []string
+		line       int
+	)
+
+	s := bufio.NewScanner(f)
+
+	for s.Scan() {
+		line++
+
+		text := s.Text()
+
+		switch {
+		case strings.HasPrefix(text, inputsPrefix):
+			for _, input := range strings.Fields(strings.TrimPrefix(text, inputsPrefix)) {
+				annotated = append(annotated, abs(filepath.Dir(file), input))
+			}
+		case strings.HasPrefix(text, generatePrefix):
+			d := Directive{
+				File: file,
+				Line: line,
+				Args: split(expand(strings.TrimPrefix(text, generatePrefix), file, line)),
+			}
+
+			d.Inputs, d.Annotated = annotated, annotated != nil
+			if !d.Annotated {
+				d.Inputs = Infer(d)
+			}
+
+			annotated = nil
+			directives = append(directives, d)
+		}
+	}
+
+	return directives, s.Err()
+}
+
+// expand expands the variables go generate sets that can be known without loading the package
+func expand(s, file string, line int) string {
+	return os.Expand(s, func(name string) string {
+		switch name {
+		case "GOFILE":
+			return filepath.Base(file)
+		case "GOLINE":
+			return strconv.Itoa(line)
+		case "$":
+			return "$"
+		default:
+			return "$" + name
+		}
+	})
+}
+
+// split splits a directive into arguments the way go generate does, on spaces with double quoted
+// strings kept together
+func split(s string) []string {
+	var (
+		args    []string
+		arg     strings.Builder
+		quote   bool
+		started bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '"':
+			quote = !quote
+			started = true
+		case c == '\\' && quote && i+1 < len(s):
+			i++
+			arg.WriteByte(s[i])
+		case (c == ' ' || c == '\t') && !quote:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteByte(c)
+			started = true
+		}
+	}
+
+	if started {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+// abs resolves a path relative to dir, directories are turned into a glob of every file beneath them
+func abs(dir, p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return filepath.ToSlash(p) + "/**"
+	}
+
+	return filepath.ToSlash(p)
+}
@@ -0,0 +1,158 @@
+package generate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-generate")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	write := func(name, src string) string {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+
+		return path
+	}
+
+	write("db/sqlc.yaml", `version: "2"
+sql:
+  - queries: queries
+    schema:
+      - ../migrations/schema.sql
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "db", "queries"), 0755))
+
+	file := write("pkg/gen.go", `package pkg
+
+//go:generate mockgen -source=../api/client.go -destination=mock_$GOFILE
+//go:generate go run go.uber.org/mock/mockgen@v0.4.0 -source ../api/server.go
+//go:generate go tool stringer -type=Kind ../kinds
+//go:generate stringer -type Kind .
+
+//affected:inputs templates/*.tmpl /abs/spec.yaml
+//go:generate go run ./cmd/gen
+//go:generate sqlc generate -f ../db/sqlc.yaml
+//go:generate echo "hello world"
+`)
+
+	directives, err := Scan(file, filepath.Join(root, "missing.go"))
+	require.NoError(t, err)
+	require.Len(t, directives, 7)
+
+	dir := filepath.ToSlash(root)
+
+	testCases := []struct {
+		line   int
+		tool   string
+		args   []string
+		inputs []string
+	}{
+		{
+			line:   3,
+			tool:   "mockgen",
+			args:   []string{"mockgen", "-source=../api/client.go", "-destination=mock_gen.go"},
+			inputs: []string{dir + "/api/client.go"},
+		},
+		{
+			line:   4,
+			tool:   "go.uber.org/mock/mockgen",
+			args:   []string{"go", "run", "go.uber.org/mock/mockgen@v0.4.0", "-source", "../api/server.go"},
+			inputs: []string{dir + "/api/server.go"},
+		},
+		{
+			line:   5,
+			tool:   "stringer",
+			args:   []string{"go", "tool", "stringer", "-type=Kind", "../kinds"},
+			inputs: []string{dir + "/kinds"},
+		},
+		{
+			line: 6,
+			tool: "stringer",
+			args: []string{"stringer", "-type", "Kind", "."},
+		},
+		{
+			line:   9,
+			tool:   "./cmd/gen",
+			args:   []string{"go", "run", "./cmd/gen"},
+			inputs: []string{dir + "/pkg/templates/*.tmpl", "/abs/spec.yaml"},
+		},
+		{
+			line:   10,
+			tool:   "sqlc",
+			args:   []string{"sqlc", "generate", "-f", "../db/sqlc.yaml"},
+			inputs: []string{dir + "/db/sqlc.yaml", dir + "/db/queries/**", dir + "/migrations/schema.sql"},
+		},
+		{
+			line: 11,
+			tool: "echo",
+			args: []string{"echo", "hello world"},
+		},
+	}
+
+	for i, tc := range testCases {
+		d := directives[i]
+
+		assert.Equal(t, tc.line, d.Line)
+		assert.Equal(t, tc.tool, d.Tool())
+		assert.Equal(t, tc.args, d.Args)
+		assert.Equal(t, tc.inputs, d.Inputs)
+	}
+}
+
+func TestDirectiveUses(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		tool     string
+		expected bool
+	}{
+		"Binary": {
+			args:     []string{"mockgen", "-source=x.go"},
+			tool:     "go.uber.org/mock/mockgen",
+			expected: true,
+		},
+		"GoTool": {
+			args:     []string{"go", "tool", "mockgen"},
+			tool:     "go.uber.org/mock/mockgen",
+			expected: true,
+		},
+		"GoToolPackage": {
+			args:     []string{"go", "tool", "go.uber.org/mock/mockgen"},
+			tool:     "go.uber.org/mock/mockgen",
+			expected: true,
+		},
+		"GoRun": {
+			args:     []string{"go", "run", "-mod=mod", "go.uber.org/mock/mockgen@latest"},
+			tool:     "go.uber.org/mock/mockgen",
+			expected: true,
+		},
+		"GoRunOtherPackage": {
+			args: []string{"go", "run", "github.com/golang/mock/mockgen"},
+			tool: "go.uber.org/mock/mockgen",
+		},
+		"OtherTool": {
+			args: []string{"stringer", "-type=Kind"},
+			tool: "go.uber.org/mock/mockgen",
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Directive{Args: tc.args}.Uses(tc.tool))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package generate
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// An inferFunc infers the inputs of a generator from its arguments
+type inferFunc func(dir string, args []string) []string
+
+// generators infer inputs for common generators, keyed by the base name of the tool
+var generators = map[string]inferFunc{
+	"mockgen":  mockgen,
+	"stringer": stringer,
+	"sqlc":     sqlc,
+}
+
+// Infer infers the inputs of a directive from the tool it runs, if the tool is unknown the
+// directive has no inputs. Inputs can be read from a generator's configuration file, so they may
+// change without the directive changing.
+func Infer(d Directive) []string {
+	tool, i := d.tool()
+
+	fn, ok := generators[path.Base(tool)]
+	if !ok {
+		return nil
+	}
+
+	return fn(d.Dir(), d.Args[i:])
+}
+
+// flag returns the value of a flag, in either the -name=value or -name value form
+func flag(args []string, names ...string) (string, bool) {
+	for i, arg := range args {
+		for _, name := range names {
+			for _, prefix := range []string{"-", "--"} {
+				switch {
+				case arg == prefix+name && i+1 < len(args):
+					return args[i+1], true
+				case strings.HasPrefix(arg, prefix+name+"="):
+					return strings.TrimPrefix(arg, prefix+name+"="), true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// mockgen in source mode generates mocks from the file passed to -source
+func mockgen(dir string, args []string) []string {
+	if source, ok := flag(args, "source"); ok {
+		return []string{abs(dir, source)}
+	}
+
+	return nil
+}
+
+// stringer generates from the package in the directory, or the files, passed as arguments. The
+// package's own files are already part of the package so only other directories are inputs
+func stringer(dir string, args []string) []string {
+	var inputs []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if strings.HasPrefix(arg, "-") {
+			// Skip the value of flags not written as -name=value
+			if !strings.Contains(arg, "=") && i+1 < len(args) {
+				i++
+			}
+
+			continue
+		}
+
+		if p := filepath.Join(dir, arg); p != dir {
+			inputs = append(inputs, abs(dir, arg))
+		}
+	}
+
+	return inputs
+}
+
+// sqlcConfig holds the paths of the queries and schemas in a version 1 or 2 sqlc config file
+type sqlcConfig struct {
+	Packages []sqlcPaths `yaml:"packages"`
+	SQL      []sqlcPaths `yaml:"sql"`
+}
+
+type sqlcPaths struct {
+	Queries interface{} `yaml:"queries"` // A path or list of paths
+	Schema  interface{} `yaml:"schema"`  // A path or list of paths
+}
+
+// sqlc generates from the queries and schemas in its config file
+func sqlc(dir string, args []string) []string {
+	file, ok := flag(args, "f", "file")
+	if !ok {
+		for _, name := range []string{"sqlc.yaml", "sqlc.yml", "sqlc.json"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				file = name
+				break
+			}
+		}
+	}
+
+	if file == "" {
+		return nil
+	}
+
+	config := abs(dir, file)
+	inputs := []string{config}
+
+	b, err := ioutil.ReadFile(filepath.FromSlash(config))
+	if err != nil {
+		return inputs
+	}
+
+	// JSON config files are valid YAML
+	var c sqlcConfig
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return inputs
+	}
+
+	base := filepath.Dir(filepath.FromSlash(config))
+
+	for _, p := range append(c.Packages, c.SQL...) {
+		for _, v := range []interface{}{p.Queries, p.Schema} {
+			for _, s := range paths(v) {
+				inputs = append(inputs, abs(base, s))
+			}
+		}
+	}
+
+	return inputs
+}
+
+// paths returns the paths in a value that is either a single path or a list of paths
+func paths(v interface{}) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case []interface{}:
+		var out []string
+
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,554 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/generate"
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion is bumped whenever the structure of the cache file changes
+const cacheVersion = 4
+
+// CacheDir returns the directory affected stores cached data in
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "affected"), nil
+}
+
+// PackageCache is an on-disk cache of package metadata for a single module. Each package directory
+// is fingerprinted by its Go files and the module's go.mod and go.sum, only directories whose
+// fingerprint has changed since the last load are reloaded. The //go:generate directives of each
+// package are cached alongside it.
+type PackageCache struct {
+	Dir    string // Directory the cache file is stored in
+	Module string // Module path
+	Root   string // Module root directory
+
+	directives map[string][]generate.Directive // Directives of the packages loaded from the cache, keyed by ID
+}
+
+// NewPackageCache constructs a package cache for the module rooted in the current working directory
+func NewPackageCache(module string) (*PackageCache, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := Root()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PackageCache{
+		Dir:    dir,
+		Module: module,
+		Root:   root,
+	}, nil
+}
+
+// Loader returns a PackageLoader that loads the cached module from the cache, any other modules are
+// loaded with the given loader. The returned loader is also a generate.Scanner that returns the
+// cached directives of the packages it loaded.
+func (c *PackageCache) Loader(l PackageLoader) PackageLoader {
+	return &cacheLoader{cache: c, loader: l}
+}
+
+// cacheLoader loads packages through a package cache
+type cacheLoader struct {
+	cache  *PackageCache
+	loader PackageLoader
+}
+
+func (l *cacheLoader) Load(modules ...string) ([]*packages.Package, error) {
+	var (
+		pkgs   []*packages.Package
+		others []string
+	)
+
+	for _, m := range modules {
+		if m != l.cache.Module {
+			others = append(others, m)
+			continue
+		}
+
+		cached, err := l.cache.load(l.loader)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, cached...)
+	}
+
+	if len(others) > 0 {
+		loaded, err := l.loader.Load(others...)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, loaded...)
+	}
+
+	return pkgs, nil
+}
+
+// Directives returns the cached directives of a package loaded from the cache, the Go files of any
+// other package are scanned
+func (l *cacheLoader) Directives(pkg *packages.Package) ([]generate.Directive, error) {
+	if d, ok := l.cache.directives[pkg.ID]; ok {
+		return d, nil
+	}
+
+	return generate.Scan(pkg.GoFiles...)
+}
+
+// Path returns the path to the cache file for the module
+func (c *PackageCache) Path() string {
+	sum := sha256.Sum256([]byte(c.Root))
+
+	return filepath.Join(c.Dir, "packages-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Entries map[string]cacheEntry `json:"entries"` // Keyed by directory relative to the module root
+}
+
+type cacheEntry struct {
+	Fingerprint string         `json:"fingerprint"`
+	Packages    []cachePackage `json:"packages"`
+}
+
+type cachePackage struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	PkgPath    string            `json:"pkgPath"`
+	GoFiles    []string          `json:"goFiles"`          // Relative to the module root
+	OtherFiles []string          `json:"otherFiles"`       // Relative to the module root
+	Embeds     []string          `json:"embeds,omitempty"` // Embed patterns, relative to the module root
+	Generate   []cacheDirective  `json:"generate,omitempty"`
+	Imports    map[string]string `json:"imports"` // Import path to package ID
+	Errors     []packages.Error  `json:"errors,omitempty"`
+}
+
+// cacheDirective is a //go:generate directive, inferred inputs are not cached since they can be read
+// from a generator's configuration file which is not part of the fingerprint
+type cacheDirective struct {
+	File   string   `json:"file"` // Relative to the module root
+	Line   int      `json:"line"`
+	Args   []string `json:"args"`
+	Inputs []string `json:"inputs,omitempty"` // Annotated inputs, relative to the module root
+}
+
+func (c *PackageCache) load(l PackageLoader) ([]*packages.Package, error) {
+	fingerprints, err := c.fingerprints()
+	if err != nil {
+		return nil, err
+	}
+
+	f := c.read()
+
+	var stale []string
+
+	for dir, fingerprint := range fingerprints {
+		if e, ok := f.Entries[dir]; !ok || e.Fingerprint != fingerprint {
+			stale = append(stale, dir)
+		}
+	}
+
+	entries := make(map[string]cacheEntry, len(fingerprints))
+
+	for dir, fingerprint := range fingerprints {
+		if e, ok := f.Entries[dir]; ok && e.Fingerprint == fingerprint {
+			entries[dir] = e
+		}
+	}
+
+	if len(stale) > 0 {
+		loaded, err := l.Load(c.patterns(stale)...)
+		if err != nil {
+			return nil, err
+		}
+
+		// Directories beneath a stale directory are reloaded too, so their entries are replaced
+		// the first time one of their packages is seen
+		refreshed := make(map[string]struct{}, len(stale))
+
+		for _, dir := range stale {
+			entries[dir] = cacheEntry{Fingerprint: fingerprints[dir]}
+			refreshed[dir] = struct{}{}
+		}
+
+		for _, pkg := range loaded {
+			dir := c.dir(pkg.ID)
+
+			fingerprint, ok := fingerprints[dir]
+			if !ok {
+				continue
+			}
+
+			e := entries[dir]
+			if _, ok := refreshed[dir]; !ok {
+				e = cacheEntry{Fingerprint: fingerprint}
+				refreshed[dir] = struct{}{}
+			}
+
+			// Packages that failed to load are never served from the cache, the error may be caused
+			// by another package which can change without this package's fingerprint changing
+			if len(pkg.Errors) > 0 {
+				e.Fingerprint = ""
+			}
+
+			p, err := c.encode(pkg)
+			if err != nil {
+				return nil, err
+			}
+
+			e.Packages = append(e.Packages, p)
+			entries[dir] = e
+		}
+	}
+
+	if len(stale) > 0 || len(entries) != len(f.Entries) {
+		if err := c.write(cacheFile{Version: cacheVersion, Entries: entries}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Packages are returned in directory order so graphs built from them are the same on every run
+	dirs := make([]string, 0, len(entries))
+	for dir := range entries {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	pkgs := make([]*packages.Package, 0, len(entries))
+	c.directives = make(map[string][]generate.Directive, len(entries))
+
+	for _, dir := range dirs {
+		for _, pkg := range entries[dir].Packages {
+			pkgs = append(pkgs, c.decode(pkg))
+		}
+	}
+
+	return pkgs, nil
+}
+
+// patterns returns the module patterns to load for the stale directories, directories beneath
+// another stale directory are loaded by their ancestor's pattern
+func (c *PackageCache) patterns(stale []string) []string {
+	sort.Strings(stale)
+
+	patterns := make([]string, 0, len(stale))
+
+	var prev string
+
+	for i, dir := range stale {
+		if i > 0 && (prev == "." || strings.HasPrefix(dir, prev+"/")) {
+			continue
+		}
+
+		prev = dir
+
+		if dir == "." {
+			patterns = append(patterns, c.Module)
+			continue
+		}
+
+		patterns = append(patterns, c.Module+"/"+dir)
+	}
+
+	return patterns
+}
+
+// dir returns the directory, relative to the module root, of a package in the module
+func (c *PackageCache) dir(id string) string {
+	if id == c.Module {
+		return "."
+	}
+
+	return strings.TrimPrefix(id, c.Module+"/")
+}
+
+// fingerprints walks the module returning a fingerprint for every directory containing Go files
+func (c *PackageCache) fingerprints() (map[string]string, error) {
+	base := sha256.New()
+
+	for _, name := range []string{"go.mod", "go.sum"} {
+		if err := hashFile(base, filepath.Join(c.Root, name)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	// Settings in the environment change which files make up a package
+	out, err := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	base.Write(out)
+
+	sum := base.Sum(nil)
+	fingerprints := make(map[string]string)
+
+	err = filepath.Walk(c.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if path != c.Root {
+			name := info.Name()
+
+			// Skip directories the go tool ignores and nested modules
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		fingerprint, err := fingerprintDir(sum, path)
+		if err != nil || fingerprint == "" {
+			return err
+		}
+
+		rel, err := filepath.Rel(c.Root, path)
+		if err != nil {
+			return err
+		}
+
+		fingerprints[filepath.ToSlash(rel)] = fingerprint
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fingerprints, nil
+}
+
+// fingerprintDir hashes the non test Go files in a directory, if the directory has no Go files an
+// empty fingerprint is returned
+func fingerprintDir(base []byte, dir string) (string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(base)
+
+	var n int
+
+	for _, info := range infos {
+		name := info.Name()
+
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		n++
+
+		io.WriteString(h, name)
+		h.Write([]byte{0})
+
+		if err := hashFile(h, filepath.Join(dir, name)); err != nil {
+			return "", err
+		}
+	}
+
+	if n == 0 {
+		return "", nil
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+// read reads the cache file, a missing, unreadable or outdated cache is treated as empty
+func (c *PackageCache) read() cacheFile {
+	empty := cacheFile{Version: cacheVersion, Entries: make(map[string]cacheEntry)}
+
+	b, err := ioutil.ReadFile(c.Path())
+	if err != nil {
+		return empty
+	}
+
+	var f cacheFile
+	if err := json.Unmarshal(b, &f); err != nil || f.Version != cacheVersion || f.Entries == nil {
+		return empty
+	}
+
+	return f
+}
+
+// write writes the cache file, the file is replaced atomically so concurrent runs never observe a
+// partially written cache
+func (c *PackageCache) write(f cacheFile) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.Dir, "packages-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.Path())
+}
+
+func (c *PackageCache) encode(pkg *packages.Package) (cachePackage, error) {
+	directives, err := generate.Scan(pkg.GoFiles...)
+	if err != nil {
+		return cachePackage{}, err
+	}
+
+	p := cachePackage{
+		ID:         pkg.ID,
+		Name:       pkg.Name,
+		PkgPath:    pkg.PkgPath,
+		GoFiles:    c.rel(pkg.GoFiles),
+		OtherFiles: c.rel(pkg.OtherFiles),
+		Embeds:     c.rel(pkg.EmbedPatterns),
+		Imports:    make(map[string]string, len(pkg.Imports)),
+		Errors:     pkg.Errors,
+	}
+
+	for path, imp := range pkg.Imports {
+		p.Imports[path] = imp.ID
+	}
+
+	for _, d := range directives {
+		cd := cacheDirective{
+			File: c.rel([]string{d.File})[0],
+			Line: d.Line,
+			Args: d.Args,
+		}
+
+		if d.Annotated {
+			cd.Inputs = c.rel(d.Inputs)
+		}
+
+		p.Generate = append(p.Generate, cd)
+	}
+
+	return p, nil
+}
+
+func (c *PackageCache) decode(p cachePackage) *packages.Package {
+	pkg := &packages.Package{
+		ID:         p.ID,
+		Name:       p.Name,
+		PkgPath:    p.PkgPath,
+		GoFiles:    c.abs(p.GoFiles),
+		OtherFiles: c.abs(p.OtherFiles),
+		Imports:    make(map[string]*packages.Package, len(p.Imports)),
+		Errors:     p.Errors,
+	}
+
+	if len(p.Embeds) > 0 {
+		pkg.EmbedPatterns = c.abs(p.Embeds)
+	}
+
+	directives := make([]generate.Directive, len(p.Generate))
+
+	for i, cd := range p.Generate {
+		d := generate.Directive{
+			File:      c.abs([]string{cd.File})[0],
+			Line:      cd.Line,
+			Args:      cd.Args,
+			Annotated: cd.Inputs != nil,
+		}
+
+		d.Inputs = c.abs(cd.Inputs)
+		if !d.Annotated {
+			d.Inputs = generate.Infer(d)
+		}
+
+		directives[i] = d
+	}
+
+	if c.directives != nil {
+		c.directives[p.ID] = directives
+	}
+
+	for path, id := range p.Imports {
+		pkg.Imports[path] = &packages.Package{ID: id, PkgPath: path}
+	}
+
+	return pkg
+}
+
+func (c *PackageCache) rel(files []string) []string {
+	out := make([]string, len(files))
+
+	for i, file := range files {
+		out[i] = file
+
+		if rel, err := filepath.Rel(c.Root, file); err == nil {
+			out[i] = filepath.ToSlash(rel)
+		}
+	}
+
+	return out
+}
+
+func (c *PackageCache) abs(files []string) []string {
+	out := make([]string, len(files))
+
+	for i, file := range files {
+		out[i] = filepath.Join(c.Root, filepath.FromSlash(file))
+	}
+
+	return out
+}
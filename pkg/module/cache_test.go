@@ -0,0 +1,157 @@
+package module
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/generate"
+	"golang.org/x/tools/go/packages"
+)
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+	require.NoError(t, ioutil.WriteFile(name, []byte(data), 0644))
+}
+
+func TestPackageCacheLoader(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-module")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	writeFile(t, filepath.Join(root, "go.mod"), "module foo.com\n")
+	writeFile(t, filepath.Join(root, "a", "a.go"), "package a\n")
+	writeFile(t, filepath.Join(root, "b", "b.go"), "package b\n")
+	writeFile(t, filepath.Join(root, "b", "c", "c.go"), "package c\n")
+	writeFile(t, filepath.Join(root, "testdata", "x.go"), "package x\n")
+
+	var loaded [][]string
+
+	// The loader mimics DefaultPackageLoader by loading every package beneath each pattern
+	l := PackageLoaderFunc(func(patterns ...string) ([]*packages.Package, error) {
+		loaded = append(loaded, patterns)
+
+		var pkgs []*packages.Package
+
+		for _, id := range []string{"foo.com/a", "foo.com/b", "foo.com/b/c"} {
+			for _, pattern := range patterns {
+				if id == pattern || strings.HasPrefix(id, pattern+"/") {
+					dir := filepath.Join(root, strings.TrimPrefix(id, "foo.com/"))
+
+					pkgs = append(pkgs, &packages.Package{
+						ID:      id,
+						GoFiles: []string{filepath.Join(dir, filepath.Base(dir)+".go")},
+						Imports: map[string]*packages.Package{"fmt": {ID: "fmt"}},
+					})
+				}
+			}
+		}
+
+		return pkgs, nil
+	})
+
+	c := &PackageCache{
+		Dir:    filepath.Join(root, ".cache"),
+		Module: "foo.com",
+		Root:   root,
+	}
+
+	load := func() []string {
+		pkgs, err := c.Loader(l).Load("foo.com")
+		require.NoError(t, err)
+
+		ids := make([]string, len(pkgs))
+		for i, pkg := range pkgs {
+			ids[i] = pkg.ID
+
+			require.Len(t, pkg.GoFiles, 1)
+			assert.True(t, strings.HasPrefix(pkg.GoFiles[0], root))
+			assert.Equal(t, "fmt", pkg.Imports["fmt"].ID)
+		}
+
+		return ids
+	}
+
+	all := []string{"foo.com/a", "foo.com/b", "foo.com/b/c"}
+
+	// Nothing is cached so every directory is loaded, b/c is loaded by its parent's pattern
+	assert.ElementsMatch(t, all, load())
+	assert.Equal(t, [][]string{{"foo.com/a", "foo.com/b"}}, loaded)
+
+	// Everything is cached so nothing is loaded
+	assert.ElementsMatch(t, all, load())
+	assert.Len(t, loaded, 1)
+
+	// Only the changed directory is loaded
+	writeFile(t, filepath.Join(root, "b", "c", "c.go"), "package c\n\nimport \"fmt\"\n")
+
+	assert.ElementsMatch(t, all, load())
+	assert.Equal(t, []string{"foo.com/b/c"}, loaded[1])
+
+	// Changes to go.mod invalidate every directory
+	writeFile(t, filepath.Join(root, "go.mod"), "module foo.com\n\ngo 1.14\n")
+
+	assert.ElementsMatch(t, all, load())
+	assert.Equal(t, []string{"foo.com/a", "foo.com/b"}, loaded[2])
+}
+
+func TestPackageCacheDirectives(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-module")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	file := filepath.Join(root, "a", "a.go")
+
+	writeFile(t, filepath.Join(root, "go.mod"), "module foo.com\n")
+	writeFile(t, file, `package a
+
+//affected:inputs api.yaml
+//go:generate oapi-codegen api.yaml
+//go:generate mockgen -source=$GOFILE -destination=mock.go
+`)
+
+	pkg := &packages.Package{ID: "foo.com/a", GoFiles: []string{file}}
+
+	l := PackageLoaderFunc(func(patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{pkg}, nil
+	})
+
+	expected := []generate.Directive{
+		{File: file, Line: 4, Args: []string{"oapi-codegen", "api.yaml"}, Inputs: []string{filepath.Join(root, "a", "api.yaml")}, Annotated: true},
+		{File: file, Line: 5, Args: []string{"mockgen", "-source=a.go", "-destination=mock.go"}, Inputs: []string{file}},
+	}
+
+	loader := (&PackageCache{Dir: filepath.Join(root, ".cache"), Module: "foo.com", Root: root}).Loader(l)
+
+	_, err = loader.Load("foo.com")
+	require.NoError(t, err)
+
+	s, ok := loader.(generate.Scanner)
+	require.True(t, ok)
+
+	directives, err := s.Directives(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, expected, directives)
+
+	// A new cache reads the directives from disk without loading or scanning the package
+
+	cached := (&PackageCache{Dir: filepath.Join(root, ".cache"), Module: "foo.com", Root: root}).Loader(PackageLoaderFunc(func(...string) ([]*packages.Package, error) {
+		return nil, errors.New("package loaded")
+	}))
+
+	_, err = cached.Load("foo.com")
+	require.NoError(t, err)
+
+	directives, err = cached.(generate.Scanner).Directives(&packages.Package{ID: "foo.com/a"})
+	require.NoError(t, err)
+	assert.Equal(t, expected, directives)
+}
@@ -0,0 +1,149 @@
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// goListPackage is the subset of the go list -json output needed to build the import graph and
+// resolve changed files to packages
+type goListPackage struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	GoFiles    []string
+	CgoFiles   []string
+	OtherFiles []string
+	Imports    []string
+	Error      *goListError
+	DepsErrors []*goListError
+
+	EmbedPatterns []string
+}
+
+type goListError struct {
+	ImportStack []string
+	Pos         string
+	Err         string
+}
+
+// GoListPackageLoader loads packages by streaming the output of go list -e -json. Packages are not
+// type-checked and are decoded one at a time as go list writes them, so the raw output is never
+// held in memory.
+func GoListPackageLoader() PackageLoader {
+	return PackageLoaderFunc(func(modules ...string) ([]*packages.Package, error) {
+		args := []string{"list", "-e", "-json"}
+		for _, m := range modules {
+			args = append(args, fmt.Sprintf("%s/...", m))
+		}
+
+		var stderr bytes.Buffer
+
+		cmd := exec.Command("go", args...)
+		cmd.Stderr = &stderr
+
+		r, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		pkgs, err := decodeGoList(r)
+		if err != nil {
+			// Drain stdout so go list can exit before we wait on it
+			_, _ = io.Copy(ioutil.Discard, r)
+			_ = cmd.Wait()
+
+			return nil, err
+		}
+
+		if err := cmd.Wait(); err != nil {
+			return nil, fmt.Errorf("go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+
+		return pkgs, nil
+	})
+}
+
+// decodeGoList decodes a stream of go list -json package objects
+func decodeGoList(r io.Reader) ([]*packages.Package, error) {
+	dec := json.NewDecoder(r)
+	pkgs := make([]*packages.Package, 0)
+
+	for {
+		var p goListPackage
+
+		if err := dec.Decode(&p); err == io.EOF {
+			return pkgs, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, p.raw())
+	}
+}
+
+// raw converts go list output to a raw package, file names are made absolute and imported packages
+// only hold their ID since that is all the graph needs to relate packages
+func (p *goListPackage) raw() *packages.Package {
+	pkg := &packages.Package{
+		ID:         p.ImportPath,
+		Name:       p.Name,
+		PkgPath:    p.ImportPath,
+		GoFiles:    make([]string, 0, len(p.GoFiles)+len(p.CgoFiles)),
+		OtherFiles: make([]string, 0, len(p.OtherFiles)),
+		Imports:    make(map[string]*packages.Package, len(p.Imports)),
+	}
+
+	for _, name := range append(p.GoFiles, p.CgoFiles...) {
+		pkg.GoFiles = append(pkg.GoFiles, filepath.Join(p.Dir, name))
+	}
+
+	for _, name := range p.OtherFiles {
+		pkg.OtherFiles = append(pkg.OtherFiles, filepath.Join(p.Dir, name))
+	}
+
+	// The all: prefix only changes which hidden files a pattern embeds, it is dropped so patterns
+	// can be made absolute like go/packages does
+	for _, pattern := range p.EmbedPatterns {
+		pattern = strings.TrimPrefix(pattern, "all:")
+		pkg.EmbedPatterns = append(pkg.EmbedPatterns, filepath.Join(p.Dir, pattern))
+	}
+
+	for _, imp := range p.Imports {
+		pkg.Imports[imp] = &packages.Package{ID: imp, PkgPath: imp}
+	}
+
+	if p.Error != nil {
+		pkg.Errors = append(pkg.Errors, p.Error.raw())
+	}
+
+	// Dependency errors are reported against the package that imports the broken dependency, that
+	// is the last package on the import stack
+	for _, e := range p.DepsErrors {
+		if n := len(e.ImportStack); n > 0 && e.ImportStack[n-1] == p.ImportPath {
+			pkg.Errors = append(pkg.Errors, e.raw())
+		}
+	}
+
+	return pkg
+}
+
+func (e *goListError) raw() packages.Error {
+	return packages.Error{
+		Pos:  e.Pos,
+		Msg:  e.Err,
+		Kind: packages.ListError,
+	}
+}
@@ -0,0 +1,70 @@
+package module
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeGoList(t *testing.T) {
+	stream := `{
+	"Dir": "/src/foo/a",
+	"ImportPath": "foo.com/a",
+	"Name": "a",
+	"GoFiles": ["a.go"],
+	"CgoFiles": ["cgo.go"],
+	"EmbedPatterns": ["static", "all:templates/*.html"],
+	"Imports": ["fmt", "foo.com/b"]
+}
+{
+	"Dir": "/src/foo/b",
+	"ImportPath": "foo.com/b",
+	"Name": "b",
+	"GoFiles": ["b.go"]
+}
+`
+
+	pkgs, err := decodeGoList(strings.NewReader(stream))
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+
+	assert.Equal(t, "foo.com/a", pkgs[0].ID)
+	assert.Equal(t, "a", pkgs[0].Name)
+	assert.Equal(t, []string{"/src/foo/a/a.go", "/src/foo/a/cgo.go"}, pkgs[0].GoFiles)
+	assert.Equal(t, []string{"/src/foo/a/static", "/src/foo/a/templates/*.html"}, pkgs[0].EmbedPatterns)
+	assert.Equal(t, "foo.com/b", pkgs[0].Imports["foo.com/b"].ID)
+	assert.Len(t, pkgs[0].Imports, 2)
+
+	assert.Equal(t, "foo.com/b", pkgs[1].ID)
+	assert.Empty(t, pkgs[1].Imports)
+	assert.Empty(t, pkgs[1].EmbedPatterns)
+}
+
+func TestDecodeGoListErrors(t *testing.T) {
+	stream := `{
+	"ImportPath": "foo.com/a",
+	"Imports": ["foo.com/missing", "foo.com/b"],
+	"DepsErrors": [
+		{"ImportStack": ["foo.com/a"], "Pos": "a/a.go:3:8", "Err": "cannot find package"},
+		{"ImportStack": ["foo.com/a", "foo.com/b"], "Pos": "b/b.go:1:1", "Err": "expected 'package'"}
+	]
+}
+{
+	"ImportPath": "foo.com/b",
+	"Error": {"ImportStack": ["foo.com/b"], "Pos": "b/b.go:1:1", "Err": "expected 'package'"}
+}
+`
+
+	pkgs, err := decodeGoList(strings.NewReader(stream))
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+
+	require.Len(t, pkgs[0].Errors, 1)
+	assert.Equal(t, "cannot find package", pkgs[0].Errors[0].Msg)
+	assert.Equal(t, "a/a.go:3:8", pkgs[0].Errors[0].Pos)
+
+	require.Len(t, pkgs[1].Errors, 1)
+	assert.Equal(t, "expected 'package'", pkgs[1].Errors[0].Msg)
+}
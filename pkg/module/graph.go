@@ -0,0 +1,497 @@
+package module
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ErrImportCycle is returned when the package graph contains an import cycle
+var ErrImportCycle = errors.New("import cycle in package graph")
+
+// MaxImportPaths is the maximum number of import paths enumerated between two packages
+const MaxImportPaths = 1000
+
+// EdgeFunc reports whether a traversal follows the import of one package by another
+type EdgeFunc func(importer, imported *Package) bool
+
+// Graph is the package import graph. Packages are indexed by ID and hold both their forward
+// (Imports) and reverse (Parents) edges, so lookups are constant time and traversals in either
+// direction are linear in the size of the graph.
+type Graph struct {
+	pkgs  []*Package          // Packages in the order they were added
+	index map[string]*Package // Packages indexed by ID
+}
+
+// NewGraph constructs a new package graph, if more than one package shares an ID only the first is
+// added to the graph
+func NewGraph(in ...*packages.Package) *Graph {
+	g := &Graph{
+		pkgs:  make([]*Package, 0, len(in)),
+		index: make(map[string]*Package, len(in)),
+	}
+
+	for _, pkg := range in {
+		if _, ok := g.index[pkg.ID]; ok {
+			continue
+		}
+
+		p := NewPackage(pkg)
+		p.idx = len(g.pkgs)
+
+		g.pkgs = append(g.pkgs, p)
+		g.index[p.ID] = p
+	}
+
+	g.relate()
+
+	return g
+}
+
+// Packages returns the packages in the graph
+func (g *Graph) Packages() []*Package {
+	return g.pkgs
+}
+
+// Len returns the number of packages in the graph
+func (g *Graph) Len() int {
+	return len(g.pkgs)
+}
+
+// Lookup returns the package with the given ID, if the package is not in the graph the return
+// value will be nil
+func (g *Graph) Lookup(id string) *Package {
+	return g.index[id]
+}
+
+// Find finds a package in the graph
+func (g *Graph) Find(fn FindPackageFunc) *Package {
+	for _, p := range g.pkgs {
+		if fn(p) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// ImportPath returns the shortest import path betwween two packages, if no path exists the return
+// value will be nil
+func (g *Graph) ImportPath(start, end *Package) ImportPath {
+	if !g.contains(start) || !g.contains(end) {
+		return nil
+	}
+
+	// Breadth first search from the end package along reverse edges, recording the next hop
+	// towards the end package so the path can be read forwards from the start package
+	next := make([]*Package, len(g.pkgs))
+	next[end.idx] = end
+
+	queue := []*Package{end}
+
+	for len(queue) > 0 && next[start.idx] == nil {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		for _, parent := range pkg.Parents {
+			if next[parent.idx] == nil {
+				next[parent.idx] = pkg
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	if next[start.idx] == nil {
+		return nil
+	}
+
+	return g.path(start, end, func(p *Package) *Package { return next[p.idx] })
+}
+
+// ImportPaths returns the shortest import path from every package that directly or indirectly
+// imports one of the given targets to each target it reaches. Each target is also returned as a
+// single element path to itself. The first element of a path is the importing package and the
+// last element is the target.
+//
+// All paths are found with a single multi-source breadth first search along reverse import edges,
+// visiting each (package, target) pair at most once.
+func (g *Graph) ImportPaths(targets ...*Package) []ImportPath {
+	return g.ImportPathsFunc(nil, targets...)
+}
+
+// ImportPathsFunc is like ImportPaths but only follows the imports for which fn returns true, a nil
+// fn follows every import
+func (g *Graph) ImportPathsFunc(fn EdgeFunc, targets ...*Package) []ImportPath {
+	type visit struct {
+		pkg    *Package
+		target int
+	}
+
+	// next holds, per target, the next hop from a package towards that target
+	next := make([][]*Package, 0, len(targets))
+	ends := make([]*Package, 0, len(targets))
+	queue := make([]visit, 0, len(targets))
+	seen := make(map[*Package]struct{}, len(targets))
+
+	for _, target := range targets {
+		if _, ok := seen[target]; ok || !g.contains(target) {
+			continue
+		}
+
+		seen[target] = struct{}{}
+
+		hops := make([]*Package, len(g.pkgs))
+		hops[target.idx] = target
+
+		queue = append(queue, visit{target, len(ends)})
+		next = append(next, hops)
+		ends = append(ends, target)
+	}
+
+	paths := make([]ImportPath, 0, len(queue))
+
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+
+		hops := next[v.target]
+		paths = append(paths, g.path(v.pkg, ends[v.target], func(p *Package) *Package {
+			return hops[p.idx]
+		}))
+
+		for _, parent := range v.pkg.Parents {
+			if hops[parent.idx] == nil && (fn == nil || fn(parent, v.pkg)) {
+				hops[parent.idx] = v.pkg
+				queue = append(queue, visit{parent, v.target})
+			}
+		}
+	}
+
+	return paths
+}
+
+// Routes returns the distinct import routes leading to the target package
+func (g *Graph) Routes(target *Package) *Routes {
+	return g.RoutesFunc(target, nil)
+}
+
+// RoutesFunc is like Routes but only follows the imports for which fn returns true, a nil fn
+// follows every import
+func (g *Graph) RoutesFunc(target *Package, fn EdgeFunc) *Routes {
+	return &Routes{
+		target: target,
+		follow: fn,
+		counts: make([]uint64, len(g.pkgs)),
+		dists:  make([]int, len(g.pkgs)),
+		state:  make([]int8, len(g.pkgs)),
+	}
+}
+
+// Dependents returns every package that directly or indirectly imports the given package
+func (g *Graph) Dependents(p *Package) []*Package {
+	return g.reachable(p, WalkParents)
+}
+
+// Dependencies returns every package that is directly or indirectly imported by the given package
+func (g *Graph) Dependencies(p *Package) []*Package {
+	return g.reachable(p, WalkImports)
+}
+
+func (g *Graph) reachable(p *Package, d WalkDirection) []*Package {
+	pkgs := make([]*Package, 0)
+	seen := map[*Package]struct{}{p: {}}
+
+	_ = Walk(p, d, func(pkg *Package) error {
+		if pkg == p {
+			return nil
+		}
+
+		if _, ok := seen[pkg]; ok {
+			return ErrSkipPackage
+		}
+
+		seen[pkg] = struct{}{}
+		pkgs = append(pkgs, pkg)
+
+		return nil
+	})
+
+	return pkgs
+}
+
+// TopologicalOrder returns the packages in the graph ordered so that every package appears after
+// all of the packages it imports. An error is returned if the graph contains an import cycle
+func (g *Graph) TopologicalOrder() ([]*Package, error) {
+	pending := make([]int, len(g.pkgs))
+	queue := make([]*Package, 0)
+
+	for _, pkg := range g.pkgs {
+		pending[pkg.idx] = len(pkg.Imports)
+
+		if len(pkg.Imports) == 0 {
+			queue = append(queue, pkg)
+		}
+	}
+
+	order := make([]*Package, 0, len(g.pkgs))
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		order = append(order, pkg)
+
+		for _, parent := range pkg.Parents {
+			pending[parent.idx]--
+
+			if pending[parent.idx] == 0 {
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	if len(order) != len(g.pkgs) {
+		return nil, ErrImportCycle
+	}
+
+	return order, nil
+}
+
+// relate builds the forward and reverse edges between packages in the graph using the ID index,
+// imports of packages not in the graph are ignored. Edges are sorted by ID so traversals, and the
+// import paths they find, are the same on every run.
+func (g *Graph) relate() {
+	for _, pkg := range g.pkgs {
+		for _, imp := range pkg.pkg.Imports {
+			p, ok := g.index[imp.ID]
+			if !ok {
+				continue
+			}
+
+			pkg.Imports = append(pkg.Imports, p)
+			p.Parents = append(p.Parents, pkg)
+		}
+	}
+
+	for _, pkg := range g.pkgs {
+		sortByID(pkg.Imports)
+		sortByID(pkg.Parents)
+	}
+}
+
+func sortByID(pkgs []*Package) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ID < pkgs[j].ID
+	})
+}
+
+// contains reports whether the package belongs to this graph
+func (g *Graph) contains(p *Package) bool {
+	return p != nil && p.idx < len(g.pkgs) && g.pkgs[p.idx] == p
+}
+
+// path follows next hops from start until end is reached
+func (g *Graph) path(start, end *Package, next func(*Package) *Package) ImportPath {
+	p := ImportPath{start}
+
+	for pkg := start; pkg != end; {
+		pkg = next(pkg)
+		p = append(p, pkg)
+	}
+
+	return p
+}
+
+// ImportPath holds the import path between two packages
+type ImportPath []*Package
+
+// HasNode checks if the path alreadt has the node
+func (p ImportPath) HasNode(pkg *Package) bool {
+	for _, v := range p {
+		if pkg == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Routes counts and enumerates the distinct import paths from any package in a graph to a single
+// target package. Results are memoised so a Routes value should be reused for many packages.
+type Routes struct {
+	target *Package
+	follow EdgeFunc // Imports followed, nil follows every import
+	counts []uint64 // Number of paths from a package to the target
+	dists  []int    // Length of the shortest path from a package to the target
+	state  []int8   // Visit state of a package, see the route* constants
+}
+
+const (
+	routeUnvisited int8 = iota
+	routeVisiting
+	routeVisited
+)
+
+// Count returns the number of distinct import paths from the package to the target, the count
+// saturates at the maximum uint64 value
+func (r *Routes) Count(from *Package) uint64 {
+	r.visit(from)
+
+	return r.counts[from.idx]
+}
+
+// Paths returns up to limit distinct import paths from the package to the target, shortest first.
+// Paths are enumerated in order of length so the limit always keeps the shortest paths. A limit < 1
+// returns every path up to MaxImportPaths.
+func (r *Routes) Paths(from *Package, limit int) []ImportPath {
+	if limit < 1 || limit > MaxImportPaths {
+		limit = MaxImportPaths
+	}
+
+	if r.Count(from) == 0 {
+		return nil
+	}
+
+	paths := make([]ImportPath, 0)
+
+	// Partial paths are extended best first by the length of their shortest completion, which the
+	// memoised distances give exactly, so complete paths are found in order of length
+	queue := &routeQueue{}
+	heap.Push(queue, routePath{path: ImportPath{from}, length: r.dists[from.idx]})
+
+	for queue.Len() > 0 && len(paths) < limit {
+		p := heap.Pop(queue).(routePath)
+		pkg := p.path[len(p.path)-1]
+
+		if pkg == r.target {
+			paths = append(paths, p.path)
+			continue
+		}
+
+		for _, imp := range r.imports(pkg) {
+			if p.path.HasNode(imp) {
+				continue
+			}
+
+			path := make(ImportPath, len(p.path), len(p.path)+1)
+			copy(path, p.path)
+
+			heap.Push(queue, routePath{
+				path:   append(path, imp),
+				length: len(p.path) + r.dists[imp.idx],
+			})
+		}
+	}
+
+	return paths
+}
+
+// A routePath is a partial import path and the length of its shortest completion
+type routePath struct {
+	path   ImportPath
+	length int
+	seq    int // Order the path was queued, keeping the order of equal length paths stable
+}
+
+// routeQueue is a priority queue of partial import paths, shortest completion first
+type routeQueue struct {
+	paths []routePath
+	seq   int
+}
+
+func (q *routeQueue) Len() int { return len(q.paths) }
+
+func (q *routeQueue) Less(i, j int) bool {
+	if q.paths[i].length != q.paths[j].length {
+		return q.paths[i].length < q.paths[j].length
+	}
+
+	return q.paths[i].seq < q.paths[j].seq
+}
+
+func (q *routeQueue) Swap(i, j int) { q.paths[i], q.paths[j] = q.paths[j], q.paths[i] }
+
+func (q *routeQueue) Push(x interface{}) {
+	p := x.(routePath)
+	p.seq = q.seq
+	q.seq++
+
+	q.paths = append(q.paths, p)
+}
+
+func (q *routeQueue) Pop() interface{} {
+	p := q.paths[len(q.paths)-1]
+	q.paths = q.paths[:len(q.paths)-1]
+
+	return p
+}
+
+// imports returns the packages imported by pkg that lead to the target, nearest first
+func (r *Routes) imports(pkg *Package) []*Package {
+	imports := make([]*Package, 0, len(pkg.Imports))
+
+	for _, imp := range pkg.Imports {
+		if r.follows(pkg, imp) && r.Count(imp) > 0 {
+			imports = append(imports, imp)
+		}
+	}
+
+	sort.SliceStable(imports, func(i, j int) bool {
+		return r.dists[imports[i].idx] < r.dists[imports[j].idx]
+	})
+
+	return imports
+}
+
+func (r *Routes) visit(pkg *Package) {
+	if r.state[pkg.idx] != routeUnvisited {
+		return
+	}
+
+	if pkg == r.target {
+		r.counts[pkg.idx] = 1
+		r.state[pkg.idx] = routeVisited
+
+		return
+	}
+
+	r.state[pkg.idx] = routeVisiting
+
+	var count uint64
+
+	dist := math.MaxInt32
+
+	for _, imp := range pkg.Imports {
+		if !r.follows(pkg, imp) {
+			continue
+		}
+
+		r.visit(imp)
+
+		// Skip packages that can't reach the target and packages still being visited, which
+		// would only be the case if the graph had an import cycle
+		if r.state[imp.idx] != routeVisited || r.counts[imp.idx] == 0 {
+			continue
+		}
+
+		if count += r.counts[imp.idx]; count < r.counts[imp.idx] {
+			count = math.MaxUint64
+		}
+
+		if d := r.dists[imp.idx] + 1; d < dist {
+			dist = d
+		}
+	}
+
+	r.counts[pkg.idx] = count
+	r.dists[pkg.idx] = dist
+	r.state[pkg.idx] = routeVisited
+}
+
+// follows reports whether routes follow the import of imported by importer
+func (r *Routes) follows(importer, imported *Package) bool {
+	return r.follow == nil || r.follow(importer, imported)
+}
@@ -0,0 +1,449 @@
+package module
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+)
+
+// testPackages builds raw packages from a map of package ID to the IDs it imports
+func testPackages(imports map[string][]string) []*packages.Package {
+	pkgs := make([]*packages.Package, 0, len(imports))
+
+	for id, deps := range imports {
+		pkg := &packages.Package{
+			ID:      id,
+			PkgPath: id,
+			Imports: make(map[string]*packages.Package, len(deps)),
+		}
+
+		for _, dep := range deps {
+			pkg.Imports[dep] = &packages.Package{ID: dep}
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs
+}
+
+func ids(pkgs []*Package) []string {
+	out := make([]string, len(pkgs))
+
+	for i, pkg := range pkgs {
+		out[i] = pkg.ID
+	}
+
+	return out
+}
+
+var testImports = map[string][]string{
+	"foo.com/cmd/a":    {"foo.com/pkg/svc", "fmt"},
+	"foo.com/cmd/b":    {"foo.com/pkg/log"},
+	"foo.com/pkg/svc":  {"foo.com/pkg/log", "foo.com/pkg/util"},
+	"foo.com/pkg/log":  {"foo.com/pkg/util"},
+	"foo.com/pkg/util": {},
+}
+
+func TestNewGraphRelatesParentsAndImports(t *testing.T) {
+	g := NewGraph(testPackages(testImports)...)
+
+	log := g.Find(FindPackageByID("foo.com/pkg/log"))
+	require.NotNil(t, log)
+
+	assert.ElementsMatch(t, []string{"foo.com/cmd/b", "foo.com/pkg/svc"}, ids(log.Parents))
+	assert.ElementsMatch(t, []string{"foo.com/pkg/util"}, ids(log.Imports))
+}
+
+func TestGraphDependents(t *testing.T) {
+	testCases := map[string]struct {
+		pkg      string
+		expected []string
+	}{
+		"ReturnsTransitiveImporters": {
+			pkg:      "foo.com/pkg/util",
+			expected: []string{"foo.com/cmd/a", "foo.com/cmd/b", "foo.com/pkg/svc", "foo.com/pkg/log"},
+		},
+		"ReturnsNothingForTopLevelPackage": {
+			pkg:      "foo.com/cmd/a",
+			expected: []string{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := NewGraph(testPackages(testImports)...)
+
+			assert.ElementsMatch(t, tc.expected, ids(g.Dependents(g.Find(FindPackageByID(tc.pkg)))))
+		})
+	}
+}
+
+func TestGraphDependencies(t *testing.T) {
+	testCases := map[string]struct {
+		pkg      string
+		expected []string
+	}{
+		"ReturnsTransitiveImports": {
+			pkg:      "foo.com/cmd/a",
+			expected: []string{"foo.com/pkg/svc", "foo.com/pkg/log", "foo.com/pkg/util"},
+		},
+		"ReturnsNothingForLeafPackage": {
+			pkg:      "foo.com/pkg/util",
+			expected: []string{},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := NewGraph(testPackages(testImports)...)
+
+			assert.ElementsMatch(t, tc.expected, ids(g.Dependencies(g.Find(FindPackageByID(tc.pkg)))))
+		})
+	}
+}
+
+func TestGraphTopologicalOrder(t *testing.T) {
+	g := NewGraph(testPackages(testImports)...)
+
+	order, err := g.TopologicalOrder()
+	require.NoError(t, err)
+	require.Len(t, order, len(testImports))
+
+	pos := make(map[*Package]int, len(order))
+	for i, pkg := range order {
+		pos[pkg] = i
+	}
+
+	for _, pkg := range order {
+		for _, imp := range pkg.Imports {
+			assert.True(t, pos[imp] < pos[pkg], "%s should come before %s", imp.ID, pkg.ID)
+		}
+	}
+}
+
+func TestGraphTopologicalOrderImportCycle(t *testing.T) {
+	g := NewGraph(testPackages(map[string][]string{
+		"foo.com/a": {"foo.com/b"},
+		"foo.com/b": {"foo.com/a"},
+	})...)
+
+	_, err := g.TopologicalOrder()
+	assert.Equal(t, ErrImportCycle, err)
+}
+
+func TestGraphImportPath(t *testing.T) {
+	testCases := map[string]struct {
+		start    string
+		end      string
+		expected []string
+	}{
+		"ReturnsShortestPath": {
+			start:    "foo.com/cmd/a",
+			end:      "foo.com/pkg/util",
+			expected: []string{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util"},
+		},
+		"ReturnsSingleElementPathToItself": {
+			start:    "foo.com/pkg/log",
+			end:      "foo.com/pkg/log",
+			expected: []string{"foo.com/pkg/log"},
+		},
+		"ReturnsNilWhenNoPathExists": {
+			start: "foo.com/cmd/b",
+			end:   "foo.com/pkg/svc",
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := NewGraph(testPackages(testImports)...)
+
+			path := g.ImportPath(g.Lookup(tc.start), g.Lookup(tc.end))
+			if tc.expected == nil {
+				assert.Nil(t, path)
+				return
+			}
+
+			assert.Equal(t, tc.expected, ids(path))
+		})
+	}
+}
+
+func TestGraphImportPaths(t *testing.T) {
+	g := NewGraph(testPackages(testImports)...)
+
+	paths := g.ImportPaths(g.Lookup("foo.com/pkg/log"), g.Lookup("foo.com/pkg/svc"), g.Lookup("foo.com/pkg/log"))
+
+	actual := make([][]string, len(paths))
+	for i, path := range paths {
+		actual[i] = ids(path)
+	}
+
+	assert.ElementsMatch(t, [][]string{
+		{"foo.com/pkg/log"},
+		{"foo.com/pkg/svc", "foo.com/pkg/log"},
+		{"foo.com/cmd/b", "foo.com/pkg/log"},
+		{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/log"},
+		{"foo.com/pkg/svc"},
+		{"foo.com/cmd/a", "foo.com/pkg/svc"},
+	}, actual)
+}
+
+func TestGraphImportPathsFunc(t *testing.T) {
+	g := NewGraph(testPackages(testImports)...)
+
+	// Stop at foo.com/pkg/svc, so only foo.com/cmd/b reaches foo.com/pkg/log
+	paths := g.ImportPathsFunc(func(importer, imported *Package) bool {
+		return imported.ID != "foo.com/pkg/svc"
+	}, g.Lookup("foo.com/pkg/log"))
+
+	actual := make([][]string, len(paths))
+	for i, path := range paths {
+		actual[i] = ids(path)
+	}
+
+	assert.ElementsMatch(t, [][]string{
+		{"foo.com/pkg/log"},
+		{"foo.com/pkg/svc", "foo.com/pkg/log"},
+		{"foo.com/cmd/b", "foo.com/pkg/log"},
+	}, actual)
+}
+
+// syntheticPackages builds a layered graph of n packages where each package imports up to k
+// packages from the layers below it, similar in shape to a large monorepo
+func syntheticPackages(n, k int) []*packages.Package {
+	const width = 100
+
+	r := rand.New(rand.NewSource(int64(n)))
+	pkgs := make([]*packages.Package, n)
+
+	for i := range pkgs {
+		pkgs[i] = &packages.Package{
+			ID:      fmt.Sprintf("foo.com/pkg/p%d", i),
+			Imports: make(map[string]*packages.Package),
+		}
+
+		if i < width {
+			continue
+		}
+
+		for j := 0; j < k; j++ {
+			imp := pkgs[r.Intn(i-i%width)]
+			pkgs[i].Imports[imp.ID] = imp
+		}
+	}
+
+	return pkgs
+}
+
+// naiveGraph is the original O(n²·k) relate, kept to benchmark against
+func naiveGraph(in ...*packages.Package) map[*Package][]*Package {
+	g := make(map[*Package][]*Package, len(in))
+
+	pkgs := make([]*Package, len(in))
+	for i, pkg := range in {
+		pkgs[i] = NewPackage(pkg)
+	}
+
+	for _, pkg := range pkgs {
+		children := make([]*Package, 0)
+
+		for _, imp := range pkg.pkg.Imports {
+			for _, p := range pkgs {
+				if p.ID == imp.ID {
+					children = append(children, p)
+				}
+			}
+		}
+
+		g[pkg] = children
+	}
+
+	return g
+}
+
+// naiveImportPath is the original exhaustive depth first search, kept to benchmark against
+func naiveImportPath(g map[*Package][]*Package, start, end *Package, p ImportPath) ImportPath {
+	p = append(p, start)
+
+	if start == end {
+		return p
+	}
+
+	shortest := make([]*Package, 0)
+
+	for _, node := range g[start] {
+		if !p.HasNode(node) {
+			newPath := naiveImportPath(g, node, end, p)
+			if len(newPath) > 0 && (len(shortest) == 0 || len(newPath) < len(shortest)) {
+				shortest = newPath
+			}
+		}
+	}
+
+	return shortest
+}
+
+func BenchmarkNewGraph(b *testing.B) {
+	for _, n := range []int{1000, 4000} {
+		pkgs := syntheticPackages(n, 5)
+
+		b.Run(fmt.Sprintf("Indexed/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewGraph(pkgs...)
+			}
+		})
+
+		b.Run(fmt.Sprintf("Naive/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				naiveGraph(pkgs...)
+			}
+		})
+	}
+}
+
+func BenchmarkImportPaths(b *testing.B) {
+	// The naive search enumerates every simple path so is only run against small graphs
+	for _, n := range []int{300, 1000, 4000} {
+		pkgs := syntheticPackages(n, 3)
+		g := NewGraph(pkgs...)
+		targets := g.Packages()[:10]
+
+		b.Run(fmt.Sprintf("BFS/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g.ImportPaths(targets...)
+			}
+		})
+
+		if n > 300 {
+			continue
+		}
+
+		naive := naiveGraph(pkgs...)
+		nodes := make(map[string]*Package, len(naive))
+
+		for pkg := range naive {
+			nodes[pkg.ID] = pkg
+		}
+
+		b.Run(fmt.Sprintf("Naive/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, target := range targets {
+					for pkg := range naive {
+						naiveImportPath(naive, pkg, nodes[target.ID], make(ImportPath, 0))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	testCases := map[string]struct {
+		imports  map[string][]string
+		from     string
+		to       string
+		limit    int
+		follow   EdgeFunc
+		count    uint64
+		expected [][]string
+	}{
+		"ReturnsAllPathsShortestFirst": {
+			from:  "foo.com/cmd/a",
+			to:    "foo.com/pkg/util",
+			count: 2,
+			expected: [][]string{
+				{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util"},
+				{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/log", "foo.com/pkg/util"},
+			},
+		},
+		"ReturnsPathsUpToLimit": {
+			from:  "foo.com/cmd/a",
+			to:    "foo.com/pkg/util",
+			limit: 1,
+			count: 2,
+			expected: [][]string{
+				{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util"},
+			},
+		},
+		"ReturnsShortestPathsUpToLimit": {
+			imports: map[string][]string{
+				"foo.com/cmd/a": {"foo.com/pkg/m", "foo.com/pkg/n"},
+				"foo.com/pkg/m": {"foo.com/pkg/p", "foo.com/pkg/t"},
+				"foo.com/pkg/n": {"foo.com/pkg/t"},
+				"foo.com/pkg/p": {"foo.com/pkg/t"},
+				"foo.com/pkg/t": {},
+			},
+			from:  "foo.com/cmd/a",
+			to:    "foo.com/pkg/t",
+			limit: 2,
+			count: 3,
+			expected: [][]string{
+				{"foo.com/cmd/a", "foo.com/pkg/m", "foo.com/pkg/t"},
+				{"foo.com/cmd/a", "foo.com/pkg/n", "foo.com/pkg/t"},
+			},
+		},
+		"SkipsImportsNotFollowed": {
+			from:  "foo.com/cmd/a",
+			to:    "foo.com/pkg/util",
+			count: 1,
+			follow: func(importer, imported *Package) bool {
+				return importer.ID != "foo.com/pkg/svc" || imported.ID != "foo.com/pkg/log"
+			},
+			expected: [][]string{
+				{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/util"},
+			},
+		},
+		"ReturnsSingleElementPathToItself": {
+			from:     "foo.com/pkg/util",
+			to:       "foo.com/pkg/util",
+			count:    1,
+			expected: [][]string{{"foo.com/pkg/util"}},
+		},
+		"ReturnsNothingWhenNoPathExists": {
+			from: "foo.com/cmd/b",
+			to:   "foo.com/pkg/svc",
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			imports := tc.imports
+			if imports == nil {
+				imports = testImports
+			}
+
+			g := NewGraph(testPackages(imports)...)
+			r := g.RoutesFunc(g.Lookup(tc.to), tc.follow)
+
+			assert.Equal(t, tc.count, r.Count(g.Lookup(tc.from)))
+
+			var actual [][]string
+			for _, path := range r.Paths(g.Lookup(tc.from), tc.limit) {
+				actual = append(actual, ids(path))
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -2,7 +2,10 @@ package module
 
 import (
 	"encoding/json"
+	"errors"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
 // ModFile is the structure of a go.mod file
@@ -28,3 +31,18 @@ func Path() (string, error) {
 
 	return f.Module.Path, nil
 }
+
+// Root calls go env GOMOD to retrieve the root directory of the current module
+func Root() (string, error) {
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	if err != nil {
+		return "", err
+	}
+
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == "/dev/null" {
+		return "", errors.New("not in a go module")
+	}
+
+	return filepath.EvalSymlinks(filepath.Dir(gomod))
+}
@@ -14,7 +14,7 @@ var ErrSkipPackage = errors.New("skip walking this package")
 type (
 	// A GraphConstructor uses go tooling to load packages in given module
 	GraphConstructor interface {
-		Construct(modules ...string) (Graph, error)
+		Construct(modules ...string) (*Graph, error)
 	}
 
 	// A PackageLoader loads packages for one or more modules.
@@ -25,7 +25,7 @@ type (
 
 // DefaultGraphConstructor is the default graph constructor
 func DefaultGraphConstructor() GraphConstructor {
-	return GraphConstructorFunc(func(modules ...string) (Graph, error) {
+	return GraphConstructorFunc(func(modules ...string) (*Graph, error) {
 		pkgs, err := DefaultPackageLoader().Load(modules...)
 		if err != nil {
 			return nil, err
@@ -35,18 +35,27 @@ func DefaultGraphConstructor() GraphConstructor {
 	})
 }
 
-// DefaultPackageLoader is the default package loader
+// DefaultPackageLoader is the default package loader, since only package names, files and imports
+// are needed to build the import graph packages are loaded with go list without type-checking
 func DefaultPackageLoader() PackageLoader {
+	return GoListPackageLoader()
+}
+
+// TypesPackageLoader loads packages with golang.org/x/tools/go/packages, type-checking every
+// package. It is much slower than the default loader and is only needed for symbol-level analysis
+func TypesPackageLoader() PackageLoader {
 	return PackageLoaderFunc(func(modules ...string) ([]*packages.Package, error) {
 		cfg := &packages.Config{
-			Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedTypes,
+			Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedTypes |
+				packages.NeedEmbedPatterns,
 		}
 
+		patterns := make([]string, len(modules))
 		for i := range modules {
-			modules[i] = fmt.Sprintf("%s/...", modules[i])
+			patterns[i] = fmt.Sprintf("%s/...", modules[i])
 		}
 
-		pkgs, err := packages.Load(cfg, modules...)
+		pkgs, err := packages.Load(cfg, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -87,113 +96,15 @@ func FindPackageByDir(dir string) FindPackageFunc {
 }
 
 // GraphConstructorFunc is an adaptor allowing methods to act as a GraphConstructor
-type GraphConstructorFunc func(...string) (Graph, error)
+type GraphConstructorFunc func(...string) (*Graph, error)
 
 // Construct loads packages for a module
-func (fn GraphConstructorFunc) Construct(modules ...string) (Graph, error) {
+func (fn GraphConstructorFunc) Construct(modules ...string) (*Graph, error) {
 	return fn(modules...)
 }
 
-// Graph is the package import graph
-type Graph map[*Package][]*Package
-
-// NewGraph constructs a new package graph
-func NewGraph(in ...*packages.Package) Graph {
-	g := Graph{}
-
-	pkgs := make([]*Package, len(in))
-
-	for i, pkg := range in {
-		pkgs[i] = NewPackage(pkg)
-	}
-
-	g.relate(pkgs...)
-
-	return g
-}
-
-// Find finds a package in the graph
-func (g Graph) Find(fn FindPackageFunc) *Package {
-	for p := range g {
-		if fn(p) {
-			return p
-		}
-	}
-
-	return nil
-}
-
-// ImportPath returns the shortest import path betwween two packages, if no path exists the return
-// value will be nil
-func (g Graph) ImportPath(start, end *Package) ImportPath {
-	p := importPath(g, start, end, make(ImportPath, 0))
-
-	if len(p) == 0 {
-		return nil
-	}
-
-	return p
-}
-
-func importPath(g Graph, start, end *Package, p ImportPath) ImportPath {
-	if _, exist := g[start]; !exist {
-		return p
-	}
-
-	p = append(p, start)
-
-	if start == end {
-		return p
-	}
-
-	shortest := make([]*Package, 0)
-
-	for _, node := range g[start] {
-		if !p.HasNode(node) {
-			newPath := importPath(g, node, end, p)
-			if len(newPath) > 0 {
-				if len(shortest) == 0 || (len(newPath) < len(shortest)) {
-					shortest = newPath
-				}
-			}
-		}
-	}
-
-	return shortest
-}
-
-func (g Graph) relate(pkgs ...*Package) {
-	for _, pkg := range pkgs {
-		children := make([]*Package, 0)
-
-		for _, imp := range pkg.pkg.Imports {
-			for _, p := range pkgs {
-				if p.ID == imp.ID {
-					children = append(children, p)
-				}
-			}
-		}
-
-		g[pkg] = children
-	}
-}
-
-// ImportPath holds the import path between two packages
-type ImportPath []*Package
-
-// HasNode checks if the path alreadt has the node
-func (p ImportPath) HasNode(pkg *Package) bool {
-	for _, v := range p {
-		if pkg == v {
-			return true
-		}
-	}
-
-	return false
-}
-
 // ConstructGraph uses the default graph constructor to load the modules packages
-func ConstructGraph(modules ...string) (Graph, error) {
+func ConstructGraph(modules ...string) (*Graph, error) {
 	return DefaultGraphConstructor().Construct(modules...)
 }
 
@@ -225,6 +136,12 @@ type Package struct {
 	Imports []*Package `json:"-"`         // Packages imported by this package
 
 	pkg *packages.Package // Raw package
+	idx int               // Position of the package in its graph
+}
+
+// Files returns the package's Go files, excluding test files
+func (p *Package) Files() []string {
+	return p.pkg.GoFiles
 }
 
 // WalkDirection is a direction in which we can traverse the packages
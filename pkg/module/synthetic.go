@@ -0,0 +1,56 @@
+package module
+
+import (
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SyntheticPrefix prefixes the IDs of synthetic packages
+const SyntheticPrefix = "file:"
+
+// NewSyntheticPackage returns a raw package for a synthetic node, a node in the import graph that
+// stands in for non-Go files packages depend upon such as SQL migrations or OpenAPI specs
+func NewSyntheticPackage(name string) *packages.Package {
+	return &packages.Package{
+		ID:      SyntheticPrefix + name,
+		Name:    name,
+		Imports: make(map[string]*packages.Package),
+	}
+}
+
+// Synthetic reports whether the package is a synthetic node rather than a Go package
+func (p *Package) Synthetic() bool {
+	return strings.HasPrefix(p.ID, SyntheticPrefix)
+}
+
+// Depend returns the packages with each dependent package importing its dependencies, deps maps the
+// ID of a dependent package to its dependencies. Dependent packages are copied so the given packages
+// are not modified.
+func Depend(pkgs []*packages.Package, deps map[string][]*packages.Package) []*packages.Package {
+	out := make([]*packages.Package, len(pkgs))
+
+	for i, pkg := range pkgs {
+		out[i] = pkg
+
+		dependencies, ok := deps[pkg.ID]
+		if !ok {
+			continue
+		}
+
+		cp := *pkg
+		cp.Imports = make(map[string]*packages.Package, len(pkg.Imports)+len(dependencies))
+
+		for path, imp := range pkg.Imports {
+			cp.Imports[path] = imp
+		}
+
+		for _, dep := range dependencies {
+			cp.Imports[dep.ID] = dep
+		}
+
+		out[i] = &cp
+	}
+
+	return out
+}
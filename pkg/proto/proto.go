@@ -0,0 +1,137 @@
+// Package proto parses the parts of protobuf files needed to relate them to the Go packages generated
+// from them, the go_package option and the files they import. It is not a full protobuf parser.
+package proto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	comments  = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	imports   = regexp.MustCompile(`\bimport\s+(?:(?:public|weak)\s+)?"([^"]+)"\s*;`)
+	goPackage = regexp.MustCompile(`\boption\s+go_package\s*=\s*"([^"]+)"\s*;`)
+)
+
+// A File is a parsed protobuf file
+type File struct {
+	Path      string   // Absolute path to the file
+	GoPackage string   // Import path of the generated Go package, empty if the option is not set
+	Imports   []string // Imported files, as written in the import statements
+}
+
+// Parse parses the go_package option and imports from protobuf source
+func Parse(path string, src []byte) *File {
+	src = comments.ReplaceAll(src, nil)
+
+	f := &File{Path: path}
+
+	if m := goPackage.FindSubmatch(src); m != nil {
+		// The package name may follow the import path, e.g foo.com/pb/user;userpb
+		f.GoPackage = strings.SplitN(string(m[1]), ";", 2)[0]
+	}
+
+	for _, m := range imports.FindAllSubmatch(src, -1) {
+		f.Imports = append(f.Imports, string(m[1]))
+	}
+
+	return f
+}
+
+// ParseFile reads and parses a protobuf file
+func ParseFile(path string) (*File, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(path, src), nil
+}
+
+// Walk parses every protobuf file beneath root, skipping directories the go tool ignores
+func Walk(root string) ([]*File, error) {
+	var files []*File
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) != ".proto" {
+			return nil
+		}
+
+		f, err := ParseFile(path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, f)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// An Index resolves imports to parsed files
+type Index struct {
+	files []*File
+	paths map[string]*File // Files keyed by path
+	roots []string         // Import roots
+}
+
+// NewIndex indexes the files, imports are resolved relative to each of the roots
+func NewIndex(files []*File, roots ...string) *Index {
+	paths := make(map[string]*File, len(files))
+	for _, f := range files {
+		paths[f.Path] = f
+	}
+
+	return &Index{
+		files: files,
+		paths: paths,
+		roots: roots,
+	}
+}
+
+// Files returns the indexed files
+func (i *Index) Files() []*File {
+	return i.files
+}
+
+// Resolve resolves an import to a file, if the import cannot be resolved relative to a root the
+// file whose path ends with the import is used. Resolve returns nil if no file matches.
+func (i *Index) Resolve(imp string) *File {
+	for _, root := range i.roots {
+		if f, ok := i.paths[filepath.Join(root, filepath.FromSlash(imp))]; ok {
+			return f
+		}
+	}
+
+	suffix := string(filepath.Separator) + filepath.FromSlash(imp)
+
+	for _, f := range i.files {
+		if strings.HasSuffix(f.Path, suffix) {
+			return f
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package proto
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		src       string
+		goPackage string
+		imports   []string
+	}{
+		"GoPackageAndImports": {
+			src: `syntax = "proto3";
+
+package user;
+
+import "google/protobuf/timestamp.proto";
+import public "common/id.proto";
+import weak "common/legacy.proto";
+
+option go_package = "foo.com/pb/user";
+`,
+			goPackage: "foo.com/pb/user",
+			imports:   []string{"google/protobuf/timestamp.proto", "common/id.proto", "common/legacy.proto"},
+		},
+		"GoPackageName": {
+			src:       `option go_package = "foo.com/pb/user;userpb";`,
+			goPackage: "foo.com/pb/user",
+		},
+		"IgnoresComments": {
+			src: `// import "line.proto";
+/*
+import "block.proto";
+option go_package = "foo.com/pb/block";
+*/
+import "common/id.proto"; // option go_package = "foo.com/pb/trailing";
+`,
+			imports: []string{"common/id.proto"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f := Parse("user.proto", []byte(tc.src))
+
+			assert.Equal(t, tc.goPackage, f.GoPackage)
+			assert.Equal(t, tc.imports, f.Imports)
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root, err := ioutil.TempDir("", "affected-proto")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"user/user.proto", "common/id.proto", "vendor/v.proto", ".git/x.proto", "README.md"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(`import "common/id.proto";`), 0644))
+	}
+
+	files, err := Walk(root)
+	require.NoError(t, err)
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(root, "user", "user.proto"),
+		filepath.Join(root, "common", "id.proto"),
+	}, paths)
+}
+
+func TestIndexResolve(t *testing.T) {
+	files := []*File{
+		{Path: "/repo/proto/common/id.proto"},
+		{Path: "/repo/common/id.proto"},
+		{Path: "/repo/proto/user/user.proto"},
+	}
+
+	testCases := map[string]struct {
+		roots    []string
+		imp      string
+		expected string
+	}{
+		"Root": {
+			roots:    []string{"/repo"},
+			imp:      "common/id.proto",
+			expected: "/repo/common/id.proto",
+		},
+		"FirstRoot": {
+			roots:    []string{"/repo/proto", "/repo"},
+			imp:      "common/id.proto",
+			expected: "/repo/proto/common/id.proto",
+		},
+		"Suffix": {
+			roots:    []string{"/repo"},
+			imp:      "user/user.proto",
+			expected: "/repo/proto/user/user.proto",
+		},
+		"NotFound": {
+			roots: []string{"/repo"},
+			imp:   "google/protobuf/timestamp.proto",
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var path string
+			if f := NewIndex(files, tc.roots...).Resolve(tc.imp); f != nil {
+				path = f.Path
+			}
+
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+}
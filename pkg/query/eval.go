@@ -0,0 +1,393 @@
+// Package query implements a small query language over the package import graph, similar to bazel
+// query. Queries select sets of packages with patterns and functions and combine them with set
+// operations, e.g
+//
+//	rdeps(..., changed()) ^ foo.com/services/...
+//	somepath(foo.com/cmd/api, foo.com/pkg/db)
+//	deps(foo.com/cmd/api, 1) - foo.com/pkg/...
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vidsy/affected/pkg/module"
+)
+
+// Env is the environment queries are evaluated in
+type Env struct {
+	Graph    *module.Graph     // Package import graph
+	Changed  []*module.Package // Packages modified between the two refs, returned by changed()
+	Affected []*module.Package // Packages affected by the modifications, returned by affected()
+}
+
+// A function evaluates a call, args are not evaluated so functions can take numeric arguments
+type function struct {
+	min, max int // Number of arguments
+	eval     func(e *evaluator, args []Expr) (set, error)
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"deps":     {1, 2, deps},
+		"rdeps":    {2, 3, rdeps},
+		"somepath": {2, 2, somepath},
+		"allpaths": {2, 2, allpaths},
+		"changed":  {0, 0, changed},
+		"affected": {0, 0, affected},
+	}
+}
+
+// Run parses and evaluates a query, returning the matching packages. Packages are returned in the
+// order they appear in the graph, except for somepath which returns the packages in path order.
+func Run(query string, env *Env) ([]*module.Package, error) {
+	expr, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return Eval(expr, env)
+}
+
+// Eval evaluates a parsed query
+func Eval(expr Expr, env *Env) ([]*module.Package, error) {
+	e := &evaluator{
+		env:   env,
+		order: make(map[*module.Package]int, env.Graph.Len()),
+	}
+
+	for i, pkg := range env.Graph.Packages() {
+		e.order[pkg] = i
+	}
+
+	s, err := e.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.path != nil {
+		return s.path, nil
+	}
+
+	return e.sorted(s), nil
+}
+
+// set is a set of packages, sets returned by somepath also hold the path so it can be returned in
+// order when it is not combined with another set
+type set struct {
+	pkgs map[*module.Package]struct{}
+	path module.ImportPath
+}
+
+func newSet(pkgs ...*module.Package) set {
+	s := set{pkgs: make(map[*module.Package]struct{}, len(pkgs))}
+	for _, pkg := range pkgs {
+		s.pkgs[pkg] = struct{}{}
+	}
+
+	return s
+}
+
+func (s set) has(pkg *module.Package) bool {
+	_, ok := s.pkgs[pkg]
+	return ok
+}
+
+type evaluator struct {
+	env   *Env
+	order map[*module.Package]int // Position of each package in the graph
+}
+
+func (e *evaluator) sorted(s set) []*module.Package {
+	pkgs := make([]*module.Package, 0, len(s.pkgs))
+	for pkg := range s.pkgs {
+		pkgs = append(pkgs, pkg)
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return e.order[pkgs[i]] < e.order[pkgs[j]]
+	})
+
+	return pkgs
+}
+
+func (e *evaluator) eval(expr Expr) (set, error) {
+	switch x := expr.(type) {
+	case Pattern:
+		return e.pattern(x)
+	case *Call:
+		fn, ok := functions[x.Func]
+		if !ok {
+			return set{}, fmt.Errorf("unknown function %s", x.Func)
+		}
+
+		if len(x.Args) < fn.min || len(x.Args) > fn.max {
+			return set{}, fmt.Errorf("%s: expected %s arguments, got %d", x.Func, arity(fn), len(x.Args))
+		}
+
+		return fn.eval(e, x.Args)
+	case *SetOp:
+		return e.setOp(x)
+	default:
+		return set{}, fmt.Errorf("unsupported expression %s", expr)
+	}
+}
+
+func arity(fn function) string {
+	if fn.min == fn.max {
+		return strconv.Itoa(fn.min)
+	}
+
+	return fmt.Sprintf("%d to %d", fn.min, fn.max)
+}
+
+func (e *evaluator) pattern(p Pattern) (set, error) {
+	s := newSet()
+
+	prefix := strings.TrimSuffix(string(p), "/...")
+
+	for _, pkg := range e.env.Graph.Packages() {
+		switch {
+		case p == "...",
+			pkg.ID == prefix,
+			prefix != string(p) && strings.HasPrefix(pkg.ID, prefix+"/"):
+			s.pkgs[pkg] = struct{}{}
+		}
+	}
+
+	if len(s.pkgs) == 0 {
+		return set{}, fmt.Errorf("no packages match %s", p)
+	}
+
+	return s, nil
+}
+
+func (e *evaluator) setOp(op *SetOp) (set, error) {
+	left, err := e.eval(op.Left)
+	if err != nil {
+		return set{}, err
+	}
+
+	right, err := e.eval(op.Right)
+	if err != nil {
+		return set{}, err
+	}
+
+	s := newSet()
+
+	switch op.Op {
+	case "+":
+		for pkg := range left.pkgs {
+			s.pkgs[pkg] = struct{}{}
+		}
+
+		for pkg := range right.pkgs {
+			s.pkgs[pkg] = struct{}{}
+		}
+	case "-":
+		for pkg := range left.pkgs {
+			if !right.has(pkg) {
+				s.pkgs[pkg] = struct{}{}
+			}
+		}
+	case "^":
+		for pkg := range left.pkgs {
+			if right.has(pkg) {
+				s.pkgs[pkg] = struct{}{}
+			}
+		}
+	default:
+		return set{}, fmt.Errorf("unsupported operator %s", op.Op)
+	}
+
+	return s, nil
+}
+
+// depth evaluates an optional depth argument, no argument means unlimited depth
+func depth(args []Expr, i int) (int, error) {
+	if len(args) <= i {
+		return -1, nil
+	}
+
+	p, ok := args[i].(Pattern)
+	if !ok {
+		return 0, fmt.Errorf("depth must be a number, got %s", args[i])
+	}
+
+	n, err := strconv.Atoi(string(p))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("depth must be a non-negative number, got %s", p)
+	}
+
+	return n, nil
+}
+
+// reach returns the packages reachable from the set, following imports or parents, up to the max
+// depth. A negative max depth is unlimited.
+func reach(from set, max int, next func(*module.Package) []*module.Package) set {
+	s := newSet()
+
+	queue := make([]*module.Package, 0, len(from.pkgs))
+	for pkg := range from.pkgs {
+		s.pkgs[pkg] = struct{}{}
+		queue = append(queue, pkg)
+	}
+
+	for d := 0; len(queue) > 0 && (max < 0 || d < max); d++ {
+		var level []*module.Package
+
+		for _, pkg := range queue {
+			for _, n := range next(pkg) {
+				if !s.has(n) {
+					s.pkgs[n] = struct{}{}
+					level = append(level, n)
+				}
+			}
+		}
+
+		queue = level
+	}
+
+	return s
+}
+
+func imports(p *module.Package) []*module.Package {
+	return p.Imports
+}
+
+func parents(p *module.Package) []*module.Package {
+	return p.Parents
+}
+
+// deps(x, [depth]) returns x and the packages it directly or indirectly imports
+func deps(e *evaluator, args []Expr) (set, error) {
+	x, err := e.eval(args[0])
+	if err != nil {
+		return set{}, err
+	}
+
+	d, err := depth(args, 1)
+	if err != nil {
+		return set{}, err
+	}
+
+	return reach(x, d, imports), nil
+}
+
+// rdeps(universe, x, [depth]) returns x and the packages in the universe that directly or
+// indirectly import it
+func rdeps(e *evaluator, args []Expr) (set, error) {
+	universe, err := e.eval(args[0])
+	if err != nil {
+		return set{}, err
+	}
+
+	x, err := e.eval(args[1])
+	if err != nil {
+		return set{}, err
+	}
+
+	d, err := depth(args, 2)
+	if err != nil {
+		return set{}, err
+	}
+
+	s := newSet()
+
+	for pkg := range reach(x, d, parents).pkgs {
+		if universe.has(pkg) {
+			s.pkgs[pkg] = struct{}{}
+		}
+	}
+
+	return s, nil
+}
+
+// somepath(a, b) returns the packages on the shortest import path from a package in a to a package
+// in b, the result is empty if there is no path
+func somepath(e *evaluator, args []Expr) (set, error) {
+	from, err := e.eval(args[0])
+	if err != nil {
+		return set{}, err
+	}
+
+	to, err := e.eval(args[1])
+	if err != nil {
+		return set{}, err
+	}
+
+	// Breadth first search from every package in a at once, the first package in b reached ends
+	// the shortest path
+	prev := make(map[*module.Package]*module.Package)
+	queue := e.sorted(from)
+
+	for _, pkg := range queue {
+		prev[pkg] = nil
+	}
+
+	var shortest module.ImportPath
+
+	for len(queue) > 0 && shortest == nil {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		if to.has(pkg) {
+			for p := pkg; p != nil; p = prev[p] {
+				shortest = append(module.ImportPath{p}, shortest...)
+			}
+
+			break
+		}
+
+		for _, imp := range pkg.Imports {
+			if _, ok := prev[imp]; !ok {
+				prev[imp] = pkg
+				queue = append(queue, imp)
+			}
+		}
+	}
+
+	s := newSet(shortest...)
+	s.path = shortest
+
+	return s, nil
+}
+
+// allpaths(a, b) returns the packages on every import path from a package in a to a package in b
+func allpaths(e *evaluator, args []Expr) (set, error) {
+	from, err := e.eval(args[0])
+	if err != nil {
+		return set{}, err
+	}
+
+	to, err := e.eval(args[1])
+	if err != nil {
+		return set{}, err
+	}
+
+	down, up := reach(from, -1, imports), reach(to, -1, parents)
+
+	s := newSet()
+
+	for pkg := range down.pkgs {
+		if up.has(pkg) {
+			s.pkgs[pkg] = struct{}{}
+		}
+	}
+
+	return s, nil
+}
+
+// changed() returns the packages modified between the two refs
+func changed(e *evaluator, _ []Expr) (set, error) {
+	return newSet(e.env.Changed...), nil
+}
+
+// affected() returns the packages affected by the modifications between the two refs
+func affected(e *evaluator, _ []Expr) (set, error) {
+	return newSet(e.env.Affected...), nil
+}
@@ -0,0 +1,242 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators maps the symbol and keyword forms of each set operation to the symbol form
+var operators = map[string]string{
+	"+":         "+",
+	"union":     "+",
+	"-":         "-",
+	"except":    "-",
+	"^":         "^",
+	"intersect": "^",
+}
+
+// lex splits a query into tokens. Words are package patterns, function names and numbers, and may
+// be double quoted. A - is only an operator at the start of a token, so it can be used in import
+// paths.
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case c == '+' || c == '-' || c == '^':
+			tokens = append(tokens, token{tokenOp, string(c), i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote at %d", i)
+			}
+
+			tokens = append(tokens, token{tokenWord, s[i+1 : i+1+end], i})
+			i += end + 2
+		default:
+			start := i
+
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && !strings.ContainsRune(`(),+^"`, rune(s[i])) {
+				i++
+			}
+
+			word := s[start:i]
+			if op, ok := operators[word]; ok && word != op {
+				tokens = append(tokens, token{tokenOp, op, start})
+				continue
+			}
+
+			tokens = append(tokens, token{tokenWord, word, start})
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+// An Expr is a parsed query expression
+type Expr interface {
+	String() string
+}
+
+// A Pattern matches packages by import path, a pattern ending in /... also matches every package
+// beneath it and ... matches every package
+type Pattern string
+
+func (p Pattern) String() string {
+	return string(p)
+}
+
+// A Call calls a query function
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+
+	return c.Func + "(" + strings.Join(args, ", ") + ")"
+}
+
+// A SetOp is a set operation on the results of two expressions, + is the union, - the difference
+// and ^ the intersection
+type SetOp struct {
+	Op          string
+	Left, Right Expr
+}
+
+func (s *SetOp) String() string {
+	return "(" + s.Left.String() + " " + s.Op + " " + s.Right.String() + ")"
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a query. Set operations all have the same precedence and are left associative,
+// parentheses group expressions:
+//
+//	expr := term { op term }
+//	term := word | word "(" [ expr { "," expr } ] ")" | "(" expr ")"
+//	op   := "+" | "-" | "^" | "union" | "except" | "intersect"
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) expr() (Expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOp {
+		op := p.next()
+
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &SetOp{Op: op.text, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) term() (Expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenLParen:
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+
+		if c := p.next(); c.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ) at %d", c.pos)
+		}
+
+		return e, nil
+	case tokenWord:
+		if p.peek().kind != tokenLParen {
+			return Pattern(t.text), nil
+		}
+
+		p.next()
+
+		c := &Call{Func: t.text}
+
+		if p.peek().kind == tokenRParen {
+			p.next()
+			return c, nil
+		}
+
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+
+			c.Args = append(c.Args, arg)
+
+			switch n := p.next(); n.kind {
+			case tokenComma:
+				continue
+			case tokenRParen:
+				return c, nil
+			default:
+				return nil, fmt.Errorf("expected , or ) at %d", n.pos)
+			}
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+}
@@ -0,0 +1,223 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/module"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		query    string
+		expected string
+		err      bool
+	}{
+		"Pattern": {
+			query:    "foo.com/pkg/...",
+			expected: "foo.com/pkg/...",
+		},
+		"Call": {
+			query:    "deps(foo.com/cmd/a, 1)",
+			expected: "deps(foo.com/cmd/a, 1)",
+		},
+		"NoArgs": {
+			query:    "changed()",
+			expected: "changed()",
+		},
+		"LeftAssociative": {
+			query:    "a + b - c ^ d",
+			expected: "(((a + b) - c) ^ d)",
+		},
+		"Keywords": {
+			query:    "a union b except c intersect d",
+			expected: "(((a + b) - c) ^ d)",
+		},
+		"Parentheses": {
+			query:    "a - (b + c)",
+			expected: "(a - (b + c))",
+		},
+		"HyphenInPath": {
+			query:    "foo.com/my-pkg -foo.com/other-pkg",
+			expected: "(foo.com/my-pkg - foo.com/other-pkg)",
+		},
+		"Quoted": {
+			query:    `rdeps(..., "foo.com/pkg/a b")`,
+			expected: "rdeps(..., foo.com/pkg/a b)",
+		},
+		"UnclosedCall": {
+			query: "deps(a",
+			err:   true,
+		},
+		"TrailingOperator": {
+			query: "a +",
+			err:   true,
+		},
+		"UnexpectedToken": {
+			query: "a b",
+			err:   true,
+		},
+		"UnterminatedQuote": {
+			query: `"a`,
+			err:   true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			e, err := Parse(tc.query)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, e.String())
+		})
+	}
+}
+
+// testEnv builds an environment where foo.com/pkg/log is changed
+//
+//	cmd/a -> pkg/svc -> pkg/log -> pkg/util
+//	cmd/b -> pkg/log
+//	cmd/c
+func testEnv() *Env {
+	raw := func(id string, imports ...string) *packages.Package {
+		pkg := &packages.Package{ID: id, Imports: make(map[string]*packages.Package)}
+		for _, imp := range imports {
+			pkg.Imports[imp] = &packages.Package{ID: imp}
+		}
+
+		return pkg
+	}
+
+	g := module.NewGraph(
+		raw("foo.com/cmd/a", "foo.com/pkg/svc"),
+		raw("foo.com/cmd/b", "foo.com/pkg/log"),
+		raw("foo.com/cmd/c"),
+		raw("foo.com/pkg/svc", "foo.com/pkg/log"),
+		raw("foo.com/pkg/log", "foo.com/pkg/util"),
+		raw("foo.com/pkg/util"))
+
+	return &Env{
+		Graph:   g,
+		Changed: []*module.Package{g.Lookup("foo.com/pkg/log")},
+		Affected: []*module.Package{
+			g.Lookup("foo.com/cmd/a"),
+			g.Lookup("foo.com/cmd/b"),
+			g.Lookup("foo.com/pkg/svc"),
+			g.Lookup("foo.com/pkg/log"),
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	testCases := map[string]struct {
+		query    string
+		expected []string
+		err      bool
+	}{
+		"Pattern": {
+			query:    "foo.com/cmd/a",
+			expected: []string{"foo.com/cmd/a"},
+		},
+		"PatternTree": {
+			query:    "foo.com/pkg/...",
+			expected: []string{"foo.com/pkg/svc", "foo.com/pkg/log", "foo.com/pkg/util"},
+		},
+		"PatternNoMatch": {
+			query: "foo.com/nope",
+			err:   true,
+		},
+		"Deps": {
+			query:    "deps(foo.com/cmd/a)",
+			expected: []string{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/log", "foo.com/pkg/util"},
+		},
+		"DepsDepth": {
+			query:    "deps(foo.com/cmd/a, 1)",
+			expected: []string{"foo.com/cmd/a", "foo.com/pkg/svc"},
+		},
+		"DepsInvalidDepth": {
+			query: "deps(foo.com/cmd/a, foo.com/cmd/b)",
+			err:   true,
+		},
+		"RDeps": {
+			query:    "rdeps(foo.com/cmd/..., foo.com/pkg/log)",
+			expected: []string{"foo.com/cmd/a", "foo.com/cmd/b"},
+		},
+		"RDepsDepth": {
+			query:    "rdeps(..., foo.com/pkg/log, 1)",
+			expected: []string{"foo.com/cmd/b", "foo.com/pkg/svc", "foo.com/pkg/log"},
+		},
+		"SomePath": {
+			query:    "somepath(foo.com/cmd/a, foo.com/pkg/util)",
+			expected: []string{"foo.com/cmd/a", "foo.com/pkg/svc", "foo.com/pkg/log", "foo.com/pkg/util"},
+		},
+		"SomePathShortest": {
+			query:    "somepath(foo.com/cmd/..., foo.com/pkg/log)",
+			expected: []string{"foo.com/cmd/b", "foo.com/pkg/log"},
+		},
+		"SomePathNone": {
+			query:    "somepath(foo.com/cmd/c, foo.com/pkg/log)",
+			expected: []string{},
+		},
+		"AllPaths": {
+			query:    "allpaths(foo.com/cmd/..., foo.com/pkg/log)",
+			expected: []string{"foo.com/cmd/a", "foo.com/cmd/b", "foo.com/pkg/svc", "foo.com/pkg/log"},
+		},
+		"Changed": {
+			query:    "changed()",
+			expected: []string{"foo.com/pkg/log"},
+		},
+		"AffectedCommands": {
+			query:    "affected() ^ foo.com/cmd/...",
+			expected: []string{"foo.com/cmd/a", "foo.com/cmd/b"},
+		},
+		"Except": {
+			query:    "rdeps(..., changed()) - changed()",
+			expected: []string{"foo.com/cmd/a", "foo.com/cmd/b", "foo.com/pkg/svc"},
+		},
+		"Union": {
+			query:    "foo.com/cmd/c + changed()",
+			expected: []string{"foo.com/cmd/c", "foo.com/pkg/log"},
+		},
+		"UnknownFunction": {
+			query: "nope(foo.com/cmd/a)",
+			err:   true,
+		},
+		"WrongArity": {
+			query: "deps()",
+			err:   true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pkgs, err := Run(tc.query, testEnv())
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			ids := make([]string, len(pkgs))
+			for i, pkg := range pkgs {
+				ids[i] = pkg.ID
+			}
+
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
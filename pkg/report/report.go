@@ -0,0 +1,224 @@
+// Package report builds the versioned JSON report written by affected. The report wraps the
+// affected packages and groups in an envelope describing the run that produced them, the shape of
+// the report is described by the JSON Schema in schema.json.
+package report
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs"
+)
+
+// SchemaVersion is the version of the report schema, it is incremented on breaking changes
+const SchemaVersion = 1
+
+// Report is the versioned report of a run
+type Report struct {
+	SchemaVersion int                    `json:"schemaVersion"`        // Version of the report schema
+	Tool          Tool                   `json:"tool"`                 // Tool that produced the report
+	Module        string                 `json:"module"`               // Path of the analysed module
+	Refs          Refs                   `json:"refs"`                 // Refs that were compared
+	Options       map[string]interface{} `json:"options"`              // Options the run used
+	StartedAt     *time.Time             `json:"startedAt,omitempty"`  // When the run started, only with --timestamps
+	FinishedAt    *time.Time             `json:"finishedAt,omitempty"` // When the run finished, only with --timestamps
+	Changed       []string               `json:"changedFiles"`         // Files changed between the refs, repository relative
+	Modified      []Package              `json:"modified"`             // Packages with modifications between the refs
+	Affected      []Package              `json:"affected"`             // Packages directly or transitively affected by the modifications
+	Groups        []Group                `json:"groups,omitempty"`     // Groups of affected packages, only when grouping
+	Errors        []Error                `json:"errors,omitempty"`     // Errors loading packages in the module
+}
+
+// Tool describes the tool that produced a report
+type Tool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Refs describes the refs compared by a run
+type Refs struct {
+	A         Ref    `json:"a"`
+	B         Ref    `json:"b"`
+	MergeBase string `json:"mergeBase,omitempty"` // SHA of the best common ancestor of A and B
+}
+
+// A Ref is a ref as given and the SHA of the commit it resolved to
+type Ref struct {
+	Name string `json:"name"`
+	SHA  string `json:"sha,omitempty"`
+}
+
+// A Package is a modified or affected package
+type Package struct {
+	ID             string                  `json:"package"`
+	Dir            string                  `json:"directory,omitempty"`      // Repository relative, absolute for packages outside the repository
+	Classification affected.Classification `json:"classification,omitempty"` // Direct or transitive, affected packages only
+	Distance       *int                    `json:"distance,omitempty"`       // Import hops to the nearest modified package, affected packages only
+	Causes         []Cause                 `json:"causes,omitempty"`         // Why the package is affected, affected packages only
+}
+
+// A Cause is why a package is affected
+type Cause struct {
+	Kind     affected.CauseKind     `json:"kind"`
+	Category affected.CauseCategory `json:"category,omitempty"` // Most significant category of the changes
+	Package  string                 `json:"package,omitempty"`  // The modified package
+	Trigger  string                 `json:"trigger,omitempty"`  // The global trigger that fired
+	Distance int                    `json:"distance"`
+	Routes   uint64                 `json:"routes,omitempty"`
+	Imports  []string               `json:"imports,omitempty"` // Shortest import path from the affected package to the modified package
+	Paths    [][]string             `json:"paths,omitempty"`   // Distinct import paths when more than one was requested
+	Files    []string               `json:"files,omitempty"`   // Changed files that modified the package, repository relative
+	Changes  []Change               `json:"changes,omitempty"` // How each of the files changed, in file order
+	Stopped  bool                   `json:"stopped,omitempty"` // A firewall stopped the change propagating further
+}
+
+// A Change is how a changed file that modified a package changed
+type Change struct {
+	File     string                 `json:"file"`           // Repository relative
+	Kind     vcs.ChangeKind         `json:"kind,omitempty"` // Empty when the VCS did not report the change
+	From     string                 `json:"from,omitempty"` // Repository relative path a renamed or copied file came from
+	Added    int                    `json:"added"`          // Lines added
+	Deleted  int                    `json:"deleted"`        // Lines deleted
+	Binary   bool                   `json:"binary,omitempty"`
+	Category affected.CauseCategory `json:"category"`
+}
+
+// A Group is a group of affected packages
+type Group struct {
+	Name     string   `json:"group"`
+	Packages []string `json:"packages"`
+	Causes   []Cause  `json:"causes"`
+}
+
+// An Error is an error loading a package in the analysed module, packages that failed to load may
+// under-report the affected packages
+type Error struct {
+	Package  string `json:"package"`
+	Position string `json:"position,omitempty"` // Position of the error, repository relative
+	Message  string `json:"message"`
+}
+
+// New returns a report of the analysis relative to the repository root. The affected packages and
+// groups are given rather than taken from the analysis so they can be filtered, e.g to a shard.
+// Run metadata is left for the caller to fill in.
+func New(root string, a *affected.Analysis, pkgs []affected.Package, groups affected.Groups) *Report {
+	r := &Report{
+		SchemaVersion: SchemaVersion,
+		Changed:       make([]string, len(a.Changed)),
+		Modified:      make([]Package, len(a.Modified)),
+		Affected:      make([]Package, len(pkgs)),
+	}
+
+	for i, file := range a.Changed {
+		r.Changed[i] = rel(root, file)
+	}
+
+	for i, pkg := range a.Modified {
+		r.Modified[i] = Package{ID: pkg.ID, Dir: rel(root, pkg.Dir)}
+	}
+
+	for i, pkg := range pkgs {
+		distance := pkg.Distance()
+
+		r.Affected[i] = Package{
+			ID:             pkg.ID,
+			Dir:            rel(root, pkg.Dir),
+			Classification: pkg.Classification(),
+			Distance:       &distance,
+			Causes:         causes(root, pkg.Causes),
+		}
+	}
+
+	for _, e := range a.Errors {
+		r.Errors = append(r.Errors, Error{Package: e.Package, Position: rel(root, e.Pos), Message: e.Msg})
+	}
+
+	for _, g := range groups {
+		group := Group{
+			Name:     g.Name,
+			Packages: make([]string, len(g.Packages)),
+			Causes:   causes(root, g.Causes),
+		}
+
+		for i, pkg := range g.Packages {
+			group.Packages[i] = pkg.ID
+		}
+
+		r.Groups = append(r.Groups, group)
+	}
+
+	return r
+}
+
+func causes(root string, in []affected.Cause) []Cause {
+	out := make([]Cause, len(in))
+
+	for i, c := range in {
+		out[i] = Cause{
+			Kind:     c.Kind,
+			Category: c.Category,
+			Trigger:  c.Trigger,
+			Distance: c.Distance(),
+			Routes:   c.Routes,
+			Imports:  ids(c.ImportPath),
+			Stopped:  c.Stopped,
+		}
+
+		if c.Package != nil {
+			out[i].Package = c.Package.ID
+		}
+
+		for _, path := range c.ImportPaths {
+			out[i].Paths = append(out[i].Paths, ids(path))
+		}
+
+		for _, file := range c.Files {
+			out[i].Files = append(out[i].Files, rel(root, file))
+		}
+
+		for _, change := range c.Changes {
+			out[i].Changes = append(out[i].Changes, Change{
+				File:     rel(root, change.Path),
+				Kind:     change.Kind,
+				From:     rel(root, change.From),
+				Added:    change.Added,
+				Deleted:  change.Deleted,
+				Binary:   change.Binary,
+				Category: change.Category,
+			})
+		}
+	}
+
+	return out
+}
+
+func ids(path module.ImportPath) []string {
+	if len(path) == 0 {
+		return nil
+	}
+
+	out := make([]string, len(path))
+	for i, pkg := range path {
+		out[i] = pkg.ID
+	}
+
+	return out
+}
+
+// rel returns the path relative to the root using forward slashes, paths outside the root are
+// returned unchanged
+func rel(root, path string) string {
+	if path == "" || root == "" {
+		return path
+	}
+
+	r, err := filepath.Rel(root, path)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return filepath.ToSlash(r)
+}
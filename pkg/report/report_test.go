@@ -0,0 +1,280 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/affected"
+	"github.com/vidsy/affected/pkg/module"
+	"github.com/vidsy/affected/pkg/vcs"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestNew(t *testing.T) {
+	graph := module.NewGraph(
+		&packages.Package{
+			ID:      "foo.com/cmd/a",
+			GoFiles: []string{"/repo/cmd/a/main.go"},
+			Imports: map[string]*packages.Package{"foo.com/pkg/log": {ID: "foo.com/pkg/log"}},
+		},
+		&packages.Package{
+			ID:      "foo.com/pkg/log",
+			GoFiles: []string{"/repo/pkg/log/log.go"},
+		},
+	)
+
+	log, a := graph.Lookup("foo.com/pkg/log"), graph.Lookup("foo.com/cmd/a")
+
+	changes := []affected.FileChange{
+		{
+			FileChange: vcs.FileChange{Path: "/repo/pkg/log/log.go", Kind: vcs.ChangeRenamed, From: "/repo/pkg/log/logger.go", Added: 2, Deleted: 1},
+			Category:   affected.CategoryGo,
+		},
+	}
+
+	analysis := &affected.Analysis{
+		Graph:    graph,
+		Changed:  []string{"/repo/go.mod", "/repo/pkg/log/log.go", "/elsewhere/x.go"},
+		Modified: []*module.Package{log},
+		Packages: []affected.Package{
+			{
+				Package: a,
+				Causes: []affected.Cause{
+					{Kind: affected.CausePackage, Category: affected.CategoryGo, Package: log, ImportPath: module.ImportPath{a, log}, Routes: 1, Files: []string{"/repo/pkg/log/log.go"}, Changes: changes},
+					{Kind: affected.CauseGlobal, Category: affected.CategoryTrigger, Trigger: "go.mod"},
+				},
+			},
+			{
+				Package: log,
+				Causes: []affected.Cause{
+					{Kind: affected.CausePackage, Package: log, ImportPath: module.ImportPath{log}, Routes: 1, Files: []string{"/repo/pkg/log/log.go"}, Stopped: true},
+				},
+			},
+		},
+		Errors: []affected.LoadError{{Package: "foo.com/pkg/db", Pos: "/repo/pkg/db/db.go:3:1", Msg: "expected declaration"}},
+	}
+
+	groups := affected.GroupPackages(affected.GroupByPkgAfterPrefix("foo.com", 0), analysis.Packages...)
+
+	// run fills in the tool, refs and options like cmd.Report does and marshals the report
+	run := func(r *Report) []byte {
+		r.Tool = Tool{Name: "affected", Version: "devel"}
+		r.Module = "foo.com"
+		r.Refs = Refs{A: Ref{Name: "origin/master", SHA: "a"}, B: Ref{Name: "HEAD", SHA: "b"}, MergeBase: "a"}
+		r.Options = map[string]interface{}{"maxDepth": 0, "include": []string{"*.go"}, "proto": true}
+
+		b, err := json.Marshal(r)
+		require.NoError(t, err)
+
+		return b
+	}
+
+	t.Run("Packages", func(t *testing.T) {
+		r := New("/repo", analysis, analysis.Packages, groups)
+
+		assert.Equal(t, SchemaVersion, r.SchemaVersion)
+		assert.Equal(t, []string{"go.mod", "pkg/log/log.go", "/elsewhere/x.go"}, r.Changed)
+		assert.Equal(t, []Package{{ID: "foo.com/pkg/log", Dir: "pkg/log"}}, r.Modified)
+		assert.Equal(t, []Error{{Package: "foo.com/pkg/db", Position: "pkg/db/db.go:3:1", Message: "expected declaration"}}, r.Errors)
+
+		require.Len(t, r.Affected, 2)
+		assert.Equal(t, "cmd/a", r.Affected[0].Dir)
+		assert.Equal(t, affected.Transitive, r.Affected[0].Classification)
+		assert.Equal(t, affected.Direct, r.Affected[1].Classification)
+		assert.Equal(t, 0, *r.Affected[0].Distance)
+		assert.Equal(t, []Cause{
+			{
+				Kind:     affected.CausePackage,
+				Category: affected.CategoryGo,
+				Package:  "foo.com/pkg/log",
+				Distance: 1,
+				Routes:   1,
+				Imports:  []string{"foo.com/cmd/a", "foo.com/pkg/log"},
+				Files:    []string{"pkg/log/log.go"},
+				Changes: []Change{
+					{File: "pkg/log/log.go", Kind: vcs.ChangeRenamed, From: "pkg/log/logger.go", Added: 2, Deleted: 1, Category: affected.CategoryGo},
+				},
+			},
+			{Kind: affected.CauseGlobal, Category: affected.CategoryTrigger, Trigger: "go.mod"},
+		}, r.Affected[0].Causes)
+
+		assert.Equal(t, []Group{
+			{Name: "foo.com/cmd", Packages: []string{"foo.com/cmd/a"}, Causes: r.Affected[0].Causes},
+			{Name: "foo.com/pkg", Packages: []string{"foo.com/pkg/log"}, Causes: r.Affected[1].Causes},
+		}, r.Groups)
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		b, err := ioutil.ReadFile("schema.json")
+		require.NoError(t, err)
+
+		var schema map[string]interface{}
+		require.NoError(t, json.Unmarshal(b, &schema))
+
+		r := New("/repo", analysis, analysis.Packages, groups)
+		started, finished := time.Now(), time.Now()
+		r.StartedAt, r.FinishedAt = &started, &finished
+
+		var v interface{}
+		require.NoError(t, json.Unmarshal(run(r), &v))
+
+		assert.NoError(t, validate(schema, schema, v, "report"))
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		b := run(New("/repo", analysis, analysis.Packages, groups))
+
+		// By default the report has no timestamps so the same input writes byte-identical output
+		assert.Equal(t, string(b), string(run(New("/repo", analysis, analysis.Packages, groups))))
+		assert.NotContains(t, string(b), "startedAt")
+		assert.NotContains(t, string(b), "finishedAt")
+	})
+}
+
+// validate validates a value against the subset of JSON Schema used by schema.json
+func validate(root, schema map[string]interface{}, v interface{}, at string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")]
+		return validate(root, def.(map[string]interface{}), v, at)
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range all {
+			if err := validate(root, s.(map[string]interface{}), v, at); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c, ok := schema["const"]; ok && c != v {
+		return fmt.Errorf("%s: %v is not %v", at, v, c)
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !contains(enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", at, v, enum)
+	}
+
+	switch schema["type"] {
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: not an array", at)
+		}
+
+		for i, item := range items {
+			if err := validate(root, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: not a string", at)
+		}
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	required, _ := schema["required"].([]interface{})
+
+	for _, name := range required {
+		if _, ok := obj[name.(string)]; !ok {
+			return fmt.Errorf("%s: missing %s", at, name)
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+
+	for name, value := range obj {
+		prop, ok := props[name]
+		if !ok {
+			if props != nil && schema["additionalProperties"] == false {
+				return fmt.Errorf("%s: unexpected property %s", at, name)
+			}
+
+			continue
+		}
+
+		if err := validate(root, prop.(map[string]interface{}), value, at+"."+name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TestSchemaProperties checks every object in the schema has exactly the properties of the type it
+// describes, so properties spliced into the wrong object are caught
+func TestSchemaProperties(t *testing.T) {
+	b, err := ioutil.ReadFile("schema.json")
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &schema))
+
+	def := func(path ...string) map[string]interface{} {
+		s := schema
+
+		for _, p := range path {
+			s = s[p].(map[string]interface{})
+		}
+
+		return s
+	}
+
+	testCases := map[string]struct {
+		schema map[string]interface{}
+		v      interface{}
+	}{
+		"Report":  {schema: schema, v: Report{}},
+		"Tool":    {schema: def("properties", "tool"), v: Tool{}},
+		"Refs":    {schema: def("properties", "refs"), v: Refs{}},
+		"Ref":     {schema: def("$defs", "ref"), v: Ref{}},
+		"Package": {schema: def("$defs", "package"), v: Package{}},
+		"Cause":   {schema: def("$defs", "cause"), v: Cause{}},
+		"Change":  {schema: def("$defs", "change"), v: Change{}},
+		"Error":   {schema: def("$defs", "error"), v: Error{}},
+		"Group":   {schema: def("$defs", "group"), v: Group{}},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var expected []string
+
+			typ := reflect.TypeOf(tc.v)
+			for i := 0; i < typ.NumField(); i++ {
+				expected = append(expected, strings.Split(typ.Field(i).Tag.Get("json"), ",")[0])
+			}
+
+			var actual []string
+			for name := range tc.schema["properties"].(map[string]interface{}) {
+				actual = append(actual, name)
+			}
+
+			assert.ElementsMatch(t, expected, actual)
+		})
+	}
+}
@@ -0,0 +1,257 @@
+// Package runner runs a templated command for each affected package, or group of packages, with
+// bounded parallelism, streaming each command's output prefixed with the name of its target.
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// packagesArg is an argument that expands into one argument per package in the target
+const packagesArg = "{{.Packages}}"
+
+// A Target is a package, or a group of packages, a command is run for
+type Target struct {
+	Name     string   // Package import path or group name
+	Packages Packages // Packages in the target
+	Dir      string   // Package directory, empty for groups
+}
+
+// Package returns the target name, so {{.Package}} can be used for both packages and groups
+func (t Target) Package() string {
+	return t.Name
+}
+
+// Packages is a list of packages that is templated as a space separated list
+type Packages []string
+
+func (p Packages) String() string {
+	return strings.Join(p, " ")
+}
+
+// A Result is the result of running the command for a target
+type Result struct {
+	Target   Target        // Target the command was run for
+	Args     []string      // Templated command and arguments
+	ExitCode int           // Exit code of the command, -1 if the command could not be run
+	Err      error         // Error running the command, nil if the command exited with 0
+	Duration time.Duration // Time taken to run the command
+}
+
+// Passed reports whether the command exited with 0
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// A Runner runs commands for targets
+type Runner struct {
+	Jobs   int       // Maximum number of commands run at once, defaults to the number of CPUs
+	Stdout io.Writer // Writer prefixed stdout is streamed to, defaults to os.Stdout
+	Stderr io.Writer // Writer prefixed stderr is streamed to, defaults to os.Stderr
+}
+
+// Run templates the command for each target and runs them, results are returned in target order.
+// Each argument is a text/template executed with the target, an argument of exactly {{.Packages}}
+// expands into one argument per package. An error is only returned if the command cannot be
+// templated, commands that fail are reported in their result.
+func (r *Runner) Run(targets []Target, args []string) ([]Result, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no command to run")
+	}
+
+	commands := make([][]string, len(targets))
+
+	for i, t := range targets {
+		cmd, err := Template(t, args)
+		if err != nil {
+			return nil, err
+		}
+
+		commands[i] = cmd
+	}
+
+	jobs := r.Jobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+
+	stdout, stderr := r.Stdout, r.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	// Output from every command is written a line at a time so lines are never interleaved
+	mu := new(sync.Mutex)
+
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, jobs)
+	wg := new(sync.WaitGroup)
+
+	for i := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			prefix := "[" + targets[i].Name + "] "
+
+			results[i] = run(targets[i], commands[i],
+				&prefixWriter{mu: mu, w: stdout, prefix: prefix},
+				&prefixWriter{mu: mu, w: stderr, prefix: prefix})
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results, nil
+}
+
+func run(t Target, args []string, stdout, stderr *prefixWriter) Result {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
+
+	stdout.Flush()
+	stderr.Flush()
+
+	res := Result{
+		Target:   t,
+		Args:     args,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+
+	var exit *exec.ExitError
+
+	switch {
+	case err == nil:
+	case errors.As(err, &exit):
+		res.ExitCode = exit.ExitCode()
+	default:
+		res.ExitCode = -1
+	}
+
+	return res
+}
+
+// Template templates the command for the target
+func Template(t Target, args []string) ([]string, error) {
+	out := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		if arg == packagesArg {
+			out = append(out, t.Packages...)
+			continue
+		}
+
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		b := new(strings.Builder)
+		if err := tmpl.Execute(b, t); err != nil {
+			return nil, err
+		}
+
+		out = append(out, b.String())
+	}
+
+	return out, nil
+}
+
+// Failed reports whether any command failed or could not be run
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if !r.Passed() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteSummary writes a pass or fail line for each result followed by the totals
+func WriteSummary(w io.Writer, results []Result) {
+	var failed int
+
+	for _, r := range results {
+		d := r.Duration.Round(time.Millisecond)
+
+		switch {
+		case r.Passed():
+			fmt.Fprintf(w, "PASS %s (%s)\n", r.Target.Name, d)
+		case r.ExitCode < 0:
+			failed++
+			fmt.Fprintf(w, "FAIL %s (%s): %s\n", r.Target.Name, d, r.Err)
+		default:
+			failed++
+			fmt.Fprintf(w, "FAIL %s (%s): exit code %d\n", r.Target.Name, d, r.ExitCode)
+		}
+	}
+
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(results)-failed, failed)
+}
+
+// prefixWriter writes each complete line prefixed, partial lines are buffered until they are
+// completed or the writer is flushed
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+
+		if err := p.line(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes any buffered partial line
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		_ = p.line(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) line(b []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err := io.WriteString(p.w, p.prefix+string(b))
+
+	return err
+}
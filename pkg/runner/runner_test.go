@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	target := Target{
+		Name:     "foo.com/services",
+		Packages: Packages{"foo.com/services/a", "foo.com/services/b"},
+		Dir:      "/repo/services",
+	}
+
+	testCases := map[string]struct {
+		args     []string
+		expected []string
+		err      bool
+	}{
+		"Package": {
+			args:     []string{"go", "test", "{{.Package}}"},
+			expected: []string{"go", "test", "foo.com/services"},
+		},
+		"ExpandsPackages": {
+			args:     []string{"go", "test", "{{.Packages}}"},
+			expected: []string{"go", "test", "foo.com/services/a", "foo.com/services/b"},
+		},
+		"JoinsPackagesInArgument": {
+			args:     []string{"sh", "-c", "go vet {{.Packages}}"},
+			expected: []string{"sh", "-c", "go vet foo.com/services/a foo.com/services/b"},
+		},
+		"Dir": {
+			args:     []string{"make", "-C", "{{.Dir}}"},
+			expected: []string{"make", "-C", "/repo/services"},
+		},
+		"UnknownField": {
+			args: []string{"{{.Nope}}"},
+			err:  true,
+		},
+		"InvalidTemplate": {
+			args: []string{"{{.Package"},
+			err:  true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			args, err := Template(target, tc.args)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, args)
+		})
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	targets := []Target{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	r := &Runner{Jobs: 2, Stdout: stdout, Stderr: stderr}
+
+	results, err := r.Run(targets, []string{"sh", "-c", `echo out {{.Package}}; printf 'partial'; echo err >&2; test {{.Package}} != b || exit 3`})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	for i, res := range results {
+		assert.Equal(t, targets[i], res.Target)
+	}
+
+	assert.True(t, results[0].Passed())
+	assert.False(t, results[1].Passed())
+	assert.Equal(t, 3, results[1].ExitCode)
+	assert.True(t, Failed(results))
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	sort.Strings(lines)
+
+	assert.Equal(t, []string{"[a] out a", "[a] partial", "[b] out b", "[b] partial", "[c] out c", "[c] partial"}, lines)
+	assert.Equal(t, 3, strings.Count(stderr.String(), "] err\n"))
+}
+
+func TestRunnerRunNotFound(t *testing.T) {
+	r := &Runner{Stdout: new(bytes.Buffer), Stderr: new(bytes.Buffer)}
+
+	results, err := r.Run([]Target{{Name: "a"}}, []string{"affected-command-that-does-not-exist"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	assert.False(t, results[0].Passed())
+	assert.Equal(t, -1, results[0].ExitCode)
+	assert.True(t, Failed(results))
+}
+
+func TestRunnerRunBoundsJobs(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		max     int
+	)
+
+	// The writer tracks how many commands are between their start and end markers
+	w := writerFunc(func(b []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case bytes.HasSuffix(b, []byte("start\n")):
+			running++
+			if running > max {
+				max = running
+			}
+		case bytes.HasSuffix(b, []byte("end\n")):
+			running--
+		}
+	})
+
+	targets := make([]Target, 6)
+	for i := range targets {
+		targets[i] = Target{Name: string(rune('a' + i))}
+	}
+
+	r := &Runner{Jobs: 2, Stdout: w, Stderr: w}
+
+	_, err := r.Run(targets, []string{"sh", "-c", "echo start; sleep 0.05; echo end"})
+	require.NoError(t, err)
+
+	assert.True(t, max <= 2, "at most 2 commands should run at once, got %d", max)
+}
+
+func TestWriteSummary(t *testing.T) {
+	w := new(bytes.Buffer)
+
+	WriteSummary(w, []Result{
+		{Target: Target{Name: "a"}, Duration: 1500 * time.Microsecond},
+		{Target: Target{Name: "b"}, ExitCode: 2, Err: errors.New("exit status 2"), Duration: time.Second},
+		{Target: Target{Name: "c"}, ExitCode: -1, Err: errors.New("not found")},
+	})
+
+	assert.Equal(t, `PASS a (2ms)
+FAIL b (1s): exit code 2
+FAIL c (0s): not found
+1 passed, 2 failed
+`, w.String())
+}
+
+type writerFunc func([]byte)
+
+func (fn writerFunc) Write(b []byte) (int, error) {
+	fn(b)
+	return len(b), nil
+}
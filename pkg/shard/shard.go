@@ -0,0 +1,96 @@
+// Package shard deterministically splits weighted targets, such as affected packages or groups,
+// into balanced shards so they can be spread across CI workers.
+package shard
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Shard is one of Total shards, Index is one-based
+type Shard struct {
+	Index int
+	Total int
+}
+
+// Parse parses a shard in the i/N form, e.g 1/4 is the first of four shards
+func Parse(s string) (Shard, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Shard{}, fmt.Errorf("invalid shard %q, must be i/N", s)
+	}
+
+	i, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q, must be i/N", s)
+	}
+
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q, must be i/N", s)
+	}
+
+	if n < 1 || i < 1 || i > n {
+		return Shard{}, fmt.Errorf("invalid shard %q, i must be between 1 and N", s)
+	}
+
+	return Shard{Index: i, Total: n}, nil
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Total)
+}
+
+// An Item is a weighted target to shard
+type Item struct {
+	Name   string
+	Weight float64
+}
+
+// Split splits the items into n shards, balancing the total weight of each shard. Items are
+// assigned heaviest first to the lightest shard, ties are broken by name, by the number of items in
+// a shard and then by shard number, so the same items always produce the same shards. Each shard's
+// names are sorted.
+func Split(items []Item, n int) [][]string {
+	sorted := make([]Item, len(items))
+	copy(sorted, items)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weight != sorted[j].Weight {
+			return sorted[i].Weight > sorted[j].Weight
+		}
+
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	shards := make([][]string, n)
+	totals := make([]float64, n)
+
+	for _, item := range sorted {
+		lightest := 0
+
+		// Shards of equal weight are balanced by their number of items, so items without a weight
+		// are still spread across shards
+		for i := 1; i < n; i++ {
+			if totals[i] < totals[lightest] || (totals[i] == totals[lightest] && len(shards[i]) < len(shards[lightest])) {
+				lightest = i
+			}
+		}
+
+		shards[lightest] = append(shards[lightest], item.Name)
+		totals[lightest] += item.Weight
+	}
+
+	for _, names := range shards {
+		sort.Strings(names)
+	}
+
+	return shards
+}
+
+// Select returns the names of the items in the shard
+func (s Shard) Select(items []Item) []string {
+	return Split(items, s.Total)[s.Index-1]
+}
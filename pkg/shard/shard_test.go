@@ -0,0 +1,99 @@
+package shard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		shard    string
+		expected Shard
+		err      bool
+	}{
+		"First":       {shard: "1/4", expected: Shard{Index: 1, Total: 4}},
+		"Last":        {shard: "4/4", expected: Shard{Index: 4, Total: 4}},
+		"Zero":        {shard: "0/4", err: true},
+		"OutOfRange":  {shard: "5/4", err: true},
+		"NoTotal":     {shard: "1", err: true},
+		"NotANumber":  {shard: "a/4", err: true},
+		"ZeroShards":  {shard: "1/0", err: true},
+		"ExtraSlash":  {shard: "1/2/3", err: true},
+		"EmptyString": {shard: "", err: true},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := Parse(tc.shard)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, s)
+			assert.Equal(t, tc.shard, s.String())
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	items := []Item{
+		{Name: "a", Weight: 10},
+		{Name: "b", Weight: 7},
+		{Name: "c", Weight: 5},
+		{Name: "d", Weight: 3},
+		{Name: "e", Weight: 3},
+		{Name: "f", Weight: 2},
+	}
+
+	testCases := map[string]struct {
+		items    []Item
+		n        int
+		expected [][]string
+	}{
+		"Balances": {
+			items:    items,
+			n:        2,
+			expected: [][]string{{"a", "d", "f"}, {"b", "c", "e"}},
+		},
+		"IgnoresOrder": {
+			items:    []Item{items[5], items[3], items[1], items[0], items[4], items[2]},
+			n:        2,
+			expected: [][]string{{"a", "d", "f"}, {"b", "c", "e"}},
+		},
+		"MoreShardsThanItems": {
+			items:    items[:2],
+			n:        3,
+			expected: [][]string{{"a"}, {"b"}, nil},
+		},
+		"EqualWeights": {
+			items:    []Item{{Name: "c"}, {Name: "a"}, {Name: "b"}, {Name: "d"}},
+			n:        2,
+			expected: [][]string{{"a", "c"}, {"b", "d"}},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Split(tc.items, tc.n))
+		})
+	}
+}
+
+func TestShardSelect(t *testing.T) {
+	items := []Item{{Name: "a", Weight: 2}, {Name: "b", Weight: 1}, {Name: "c", Weight: 1}}
+
+	assert.Equal(t, []string{"a"}, Shard{Index: 1, Total: 2}.Select(items))
+	assert.Equal(t, []string{"b", "c"}, Shard{Index: 2, Total: 2}.Select(items))
+}
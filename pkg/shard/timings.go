@@ -0,0 +1,106 @@
+package shard
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Timings holds the historical duration of targets in seconds, keyed by target name
+type Timings map[string]float64
+
+// LoadTimings loads a timings file, if the file does not exist and is optional empty timings are
+// returned
+func LoadTimings(path string, optional bool) (Timings, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return Timings{}, nil
+		}
+
+		return nil, err
+	}
+
+	t := Timings{}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Save writes the timings file
+func (t Timings) Save(path string) error {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// testEvent is the subset of a go test -json event needed to record package durations
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+}
+
+// Record records the duration of each package in go test -json output, replacing any previous
+// duration for the package. It returns the number of packages recorded. Lines that are not test
+// events, such as build output, are ignored.
+func (t Timings) Record(r io.Reader) (int, error) {
+	var n int
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for s.Scan() {
+		var e testEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			continue
+		}
+
+		if e.Test != "" || e.Package == "" || (e.Action != "pass" && e.Action != "fail") {
+			continue
+		}
+
+		t[e.Package] = e.Elapsed
+		n++
+	}
+
+	return n, s.Err()
+}
+
+// Weight returns the duration of the target, or of the sum of its packages if the target itself has
+// no timing. The second return value is false if neither the target nor any of its packages have a
+// timing.
+func (t Timings) Weight(name string, pkgs ...string) (float64, bool) {
+	if d, ok := t[name]; ok {
+		return d, true
+	}
+
+	var (
+		total float64
+		found bool
+	)
+
+	for _, pkg := range pkgs {
+		if d, ok := t[pkg]; ok {
+			total += d
+			found = true
+		}
+	}
+
+	return total, found
+}
@@ -0,0 +1,95 @@
+package shard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimingsRecord(t *testing.T) {
+	output := `{"Action":"start","Package":"foo.com/a"}
+{"Action":"run","Package":"foo.com/a","Test":"TestA"}
+{"Action":"pass","Package":"foo.com/a","Test":"TestA","Elapsed":0.5}
+{"Action":"pass","Package":"foo.com/a","Elapsed":1.5}
+# foo.com/c
+build output
+{"Action":"fail","Package":"foo.com/b","Elapsed":3}
+{"Action":"skip","Package":"foo.com/d","Elapsed":0}
+`
+
+	timings := Timings{"foo.com/a": 10, "foo.com/e": 2}
+
+	n, err := timings.Record(strings.NewReader(output))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, n)
+	assert.Equal(t, Timings{"foo.com/a": 1.5, "foo.com/b": 3, "foo.com/e": 2}, timings)
+}
+
+func TestTimingsWeight(t *testing.T) {
+	timings := Timings{"foo.com/services/a": 4, "foo.com/services/b/api": 2, "foo.com/services/b/db": 3}
+
+	testCases := map[string]struct {
+		name     string
+		pkgs     []string
+		expected float64
+		ok       bool
+	}{
+		"Target": {
+			name:     "foo.com/services/a",
+			pkgs:     []string{"foo.com/services/a/api"},
+			expected: 4,
+			ok:       true,
+		},
+		"SumOfPackages": {
+			name:     "foo.com/services/b",
+			pkgs:     []string{"foo.com/services/b/api", "foo.com/services/b/db", "foo.com/services/b/cmd"},
+			expected: 5,
+			ok:       true,
+		},
+		"NoTimings": {
+			name: "foo.com/services/c",
+			pkgs: []string{"foo.com/services/c/api"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			w, ok := timings.Weight(tc.name, tc.pkgs...)
+
+			assert.Equal(t, tc.expected, w)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestTimingsSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "affected-timings")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ci", "timings.json")
+
+	_, err = LoadTimings(path, false)
+	assert.Error(t, err)
+
+	empty, err := LoadTimings(path, true)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	require.NoError(t, Timings{"foo.com/a": 1.25}.Save(path))
+
+	loaded, err := LoadTimings(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, Timings{"foo.com/a": 1.25}, loaded)
+}
@@ -7,13 +7,18 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/vidsy/affected/pkg/glob"
 	"github.com/vidsy/affected/pkg/vcs"
 )
 
-var _ vcs.ModifiedDirectoriesDetector = new(VCS)
+var (
+	_ vcs.ModifiedDirectoriesDetector = new(VCS)
+	_ vcs.RefResolver                 = new(VCS)
+	_ vcs.FileChangesDetector         = new(VCS)
+)
 
 // VCS provides functionality for the git version control system
 type VCS struct {
@@ -113,6 +118,180 @@ func (v *VCS) diff(a, b string, flags ...string) ([]string, error) {
 	return lines, cmd.Wait()
 }
 
+// FileChanges returns how each file changed between two git commits, with the lines added and
+// deleted. Renamed and copied files are keyed by their new path.
+func (v *VCS) FileChanges(a, b string) ([]vcs.FileChange, error) {
+	rng := fmt.Sprintf("%s..%s", a, b)
+
+	status, err := v.output("diff", "-z", "--name-status", rng)
+	if err != nil {
+		return nil, err
+	}
+
+	numstat, err := v.output("diff", "-z", "--numstat", rng)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := parseNameStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := parseNumstat(numstat)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range changes {
+		s, ok := stats[c.Path]
+		if ok {
+			changes[i].Added = s.Added
+			changes[i].Deleted = s.Deleted
+			changes[i].Binary = s.Binary
+		}
+
+		if changes[i].Path, err = v.abs(c.Path); err != nil {
+			return nil, err
+		}
+
+		if c.From == "" {
+			continue
+		}
+
+		if changes[i].From, err = v.abs(c.From); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// parseNameStatus parses the output of git diff -z --name-status, paths are left repository relative
+func parseNameStatus(out []byte) ([]vcs.FileChange, error) {
+	fields := split(out)
+	changes := make([]vcs.FileChange, 0, len(fields)/2) // nolint: mnd
+
+	for i := 0; i < len(fields); i++ {
+		status := fields[i]
+		if status == "" {
+			return nil, fmt.Errorf("git diff: empty status")
+		}
+
+		var c vcs.FileChange
+
+		switch status[0] {
+		case 'A':
+			c.Kind = vcs.ChangeAdded
+		case 'M':
+			c.Kind = vcs.ChangeModified
+		case 'D':
+			c.Kind = vcs.ChangeDeleted
+		case 'T':
+			c.Kind = vcs.ChangeType
+		case 'R':
+			c.Kind = vcs.ChangeRenamed
+		case 'C':
+			c.Kind = vcs.ChangeCopied
+		default:
+			return nil, fmt.Errorf("git diff: unsupported status %q", status)
+		}
+
+		if c.Kind == vcs.ChangeRenamed || c.Kind == vcs.ChangeCopied {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("git diff: missing paths for status %q", status)
+			}
+
+			c.From = fields[i+1]
+			i++
+		}
+
+		if i+1 >= len(fields) {
+			return nil, fmt.Errorf("git diff: missing path for status %q", status)
+		}
+
+		c.Path = fields[i+1]
+		i++
+
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// parseNumstat parses the output of git diff -z --numstat into line counts keyed by repository
+// relative path, renamed and copied files are keyed by their new path
+func parseNumstat(out []byte) (map[string]vcs.FileChange, error) {
+	fields := split(out)
+	stats := make(map[string]vcs.FileChange, len(fields))
+
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3) // nolint: mnd
+		if len(parts) != 3 {                        // nolint: mnd
+			return nil, fmt.Errorf("git diff: invalid numstat %q", fields[i])
+		}
+
+		var (
+			c   vcs.FileChange
+			err error
+		)
+
+		if parts[0] == "-" && parts[1] == "-" {
+			c.Binary = true
+		} else {
+			if c.Added, err = strconv.Atoi(parts[0]); err != nil {
+				return nil, fmt.Errorf("git diff: invalid numstat %q: %w", fields[i], err)
+			}
+
+			if c.Deleted, err = strconv.Atoi(parts[1]); err != nil {
+				return nil, fmt.Errorf("git diff: invalid numstat %q: %w", fields[i], err)
+			}
+		}
+
+		c.Path = parts[2]
+
+		// Renames and copies have an empty path followed by the old and new paths
+		if c.Path == "" {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("git diff: missing paths for numstat %q", fields[i])
+			}
+
+			c.From, c.Path = fields[i+1], fields[i+2]
+			i += 2
+		}
+
+		stats[c.Path] = c
+	}
+
+	return stats, nil
+}
+
+// split splits NUL terminated output into its fields
+func split(out []byte) []string {
+	s := strings.TrimSuffix(string(out), "\x00")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\x00")
+}
+
+func (v *VCS) abs(path string) (string, error) {
+	return filepath.Abs(filepath.Join(v.RepositoryDir, path))
+}
+
+func (v *VCS) output(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = v.RepositoryDir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+
+	return out, nil
+}
+
 // ReadFileAtRef reads a file from the repository at a given ref, e.g commit or branch.
 func (v *VCS) ReadFileAtRef(ref, name string) ([]byte, error) {
 	var buff bytes.Buffer
@@ -131,6 +310,25 @@ func (v *VCS) ReadFileAtRef(ref, name string) ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+// ResolveRef resolves a ref, e.g a branch or tag, to the SHA of the commit it points to
+func (v *VCS) ResolveRef(ref string) (string, error) {
+	return v.revision("rev-parse", "--verify", ref+"^{commit}")
+}
+
+// MergeBase returns the SHA of the best common ancestor of two refs
+func (v *VCS) MergeBase(a, b string) (string, error) {
+	return v.revision("merge-base", a, b)
+}
+
+func (v *VCS) revision(args ...string) (string, error) {
+	out, err := v.output(args...)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
 // New constructs a new git VCS
 func New() (*VCS, error) {
 	vcs := &VCS{}
@@ -1 +1,119 @@
 package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vidsy/affected/pkg/vcs"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	testCases := map[string]struct {
+		out      string
+		expected []vcs.FileChange
+		err      bool
+	}{
+		"Empty": {
+			expected: []vcs.FileChange{},
+		},
+		"Kinds": {
+			out: "A\x00a.go\x00M\x00b.go\x00D\x00c.go\x00T\x00d.go\x00",
+			expected: []vcs.FileChange{
+				{Path: "a.go", Kind: vcs.ChangeAdded},
+				{Path: "b.go", Kind: vcs.ChangeModified},
+				{Path: "c.go", Kind: vcs.ChangeDeleted},
+				{Path: "d.go", Kind: vcs.ChangeType},
+			},
+		},
+		"RenamesAndCopies": {
+			out: "R100\x00old.go\x00new.go\x00C075\x00a.go\x00b.go\x00",
+			expected: []vcs.FileChange{
+				{Path: "new.go", From: "old.go", Kind: vcs.ChangeRenamed},
+				{Path: "b.go", From: "a.go", Kind: vcs.ChangeCopied},
+			},
+		},
+		"UnsupportedStatus": {
+			out: "X\x00a.go\x00",
+			err: true,
+		},
+		"MissingPath": {
+			out: "R100\x00old.go\x00",
+			err: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			changes, err := parseNameStatus([]byte(tc.out))
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, changes)
+		})
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	testCases := map[string]struct {
+		out      string
+		expected map[string]vcs.FileChange
+		err      bool
+	}{
+		"Empty": {
+			expected: map[string]vcs.FileChange{},
+		},
+		"Lines": {
+			out: "3\t1\ta.go\x000\t12\tb.go\x00",
+			expected: map[string]vcs.FileChange{
+				"a.go": {Path: "a.go", Added: 3, Deleted: 1},
+				"b.go": {Path: "b.go", Deleted: 12},
+			},
+		},
+		"Binary": {
+			out: "-\t-\tlogo.png\x00",
+			expected: map[string]vcs.FileChange{
+				"logo.png": {Path: "logo.png", Binary: true},
+			},
+		},
+		"Rename": {
+			out: "2\t0\t\x00old.go\x00new.go\x001\t1\tc.go\x00",
+			expected: map[string]vcs.FileChange{
+				"new.go": {Path: "new.go", From: "old.go", Added: 2},
+				"c.go":   {Path: "c.go", Added: 1, Deleted: 1},
+			},
+		},
+		"Invalid": {
+			out: "a.go\x00",
+			err: true,
+		},
+		"InvalidCount": {
+			out: "x\t1\ta.go\x00",
+			err: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		tc := testCase
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			stats, err := parseNumstat([]byte(tc.out))
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, stats)
+		})
+	}
+}
@@ -37,3 +37,37 @@ type ModifiedFilesDetector interface {
 type FileAtRefReader interface {
 	ReadFileAtRef(ref, name string) ([]byte, error)
 }
+
+// A RefResolver resolves refs, e.g a branch or tag, to commits
+type RefResolver interface {
+	ResolveRef(ref string) (string, error)
+	MergeBase(a, b string) (string, error)
+}
+
+// ChangeKind is how a file changed between two refs
+type ChangeKind string
+
+// Change kinds
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeModified ChangeKind = "modified"
+	ChangeDeleted  ChangeKind = "deleted"
+	ChangeRenamed  ChangeKind = "renamed"
+	ChangeCopied   ChangeKind = "copied"
+	ChangeType     ChangeKind = "type" // The type of the file changed, e.g a file became a symlink
+)
+
+// A FileChange is a file that changed between two refs
+type FileChange struct {
+	Path    string     `json:"file"`           // Absolute path of the file
+	Kind    ChangeKind `json:"kind,omitempty"` // How the file changed
+	From    string     `json:"from,omitempty"` // Absolute path the file was renamed or copied from
+	Added   int        `json:"added"`          // Lines added
+	Deleted int        `json:"deleted"`        // Lines deleted
+	Binary  bool       `json:"binary,omitempty"`
+}
+
+// A FileChangesDetector can detect how files changed between two refs
+type FileChangesDetector interface {
+	FileChanges(a, b string) ([]FileChange, error)
+}